@@ -0,0 +1,422 @@
+package cross
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Builder runs make.bash (Setup), "go build" (Build), or any go command (Go) for a set of platforms
+type Builder struct {
+	Toolchain *Toolchain
+
+	Stash    string // Directory to deposit built files into
+	Exe      bool   // Add an .exe extension to files built for windows/*
+	Strip    bool   // Strip built files of symbols and DWARF (-ldflags "-s -w")
+	Compress bool   // Compress built files with upx (if available and supported)
+	Hook     Hook
+	FailFast bool          // Stop at the first platform to fail (cancelling the rest), instead of keeping going
+	Timeout  time.Duration // The most time to spend on each platform (make.bash, or hooks and go build, etc.)
+	Retries  int           // How many times to retry a failed make.bash, go build, etc. (unless the failure is permanent)
+	DryRun   bool          // Print each command (see Print), instead of running it
+	Print    io.Writer     // Print each command (working directory, environment override, and command line) here, as it runs
+	Native   string        // What to do with the native platform: NativeInclude, NativeExclude, NativeOnly, or "" (see native)
+	Module   Module        // Where (and how) go finds the module being built: -C, -modfile, -mod, $GOWORK, etc.
+
+	Force   bool // Setup: run make.bash, even if it already has
+	Jobs    int  // Setup: run make.bash for this many platforms at once (each in a copy of $GOROOT)
+	Verbose bool // Setup: pass make.bash output to Stdout/Stderr (instead of logging)
+	Discard bool // Setup: discard make.bash output (instead of logging)
+
+	Stdin  io.Reader // (A command with a terminal for stdin is put in the foreground, see runCommand)
+	Stdout io.Writer
+	Stderr io.Writer
+	Log    *Logger // Progress: "# Build: ...", "! linux/arm: ...", etc. (nil for none)
+
+	printLock sync.Mutex
+}
+
+// Option customizes a Builder, see NewBuilder
+type Option func(*Builder)
+
+func WithStash(directory string) Option {
+	return func(self *Builder) {
+		self.Stash = directory
+	}
+}
+
+func WithExe() Option {
+	return func(self *Builder) {
+		self.Exe = true
+	}
+}
+
+func WithStrip() Option {
+	return func(self *Builder) {
+		self.Strip = true
+	}
+}
+
+func WithCompress() Option {
+	return func(self *Builder) {
+		self.Compress = true
+	}
+}
+
+func WithHook(hook Hook) Option {
+	return func(self *Builder) {
+		self.Hook = hook
+	}
+}
+
+func WithFailFast() Option {
+	return func(self *Builder) {
+		self.FailFast = true
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(self *Builder) {
+		self.Timeout = timeout
+	}
+}
+
+func WithRetries(retries int) Option {
+	return func(self *Builder) {
+		self.Retries = retries
+	}
+}
+
+// WithDryRun prints each command to print, instead of running it
+func WithDryRun(print io.Writer) Option {
+	return func(self *Builder) {
+		self.DryRun = true
+		self.Print = print
+	}
+}
+
+// WithPrint prints each command to print, as it runs
+func WithPrint(print io.Writer) Option {
+	return func(self *Builder) {
+		self.Print = print
+	}
+}
+
+func WithNative(policy string) Option {
+	return func(self *Builder) {
+		self.Native = policy
+	}
+}
+
+func WithModule(module Module) Option {
+	return func(self *Builder) {
+		self.Module = module
+	}
+}
+
+func WithJobs(jobs int) Option {
+	return func(self *Builder) {
+		self.Jobs = jobs
+	}
+}
+
+// WithOutput sends the output of go (and make.bash, hooks, etc.) to stdout and stderr
+func WithOutput(stdout io.Writer, stderr io.Writer) Option {
+	return func(self *Builder) {
+		self.Stdout = stdout
+		self.Stderr = stderr
+	}
+}
+
+// WithLog sends progress to log (which is otherwise discarded)
+func WithLog(log *Logger) Option {
+	return func(self *Builder) {
+		self.Log = log
+	}
+}
+
+// NewBuilder returns a Builder for toolchain, passing input and output through to os.Stdin, os.Stdout, and os.Stderr
+//
+//	builder := cross.NewBuilder(toolchain, cross.WithStash("dist"), cross.WithExe())
+func NewBuilder(toolchain *Toolchain, option ...Option) *Builder {
+	self := &Builder{
+		Toolchain: toolchain,
+		Jobs:      1,
+		Stdin:     os.Stdin,
+		Stdout:    os.Stdout,
+		Stderr:    os.Stderr,
+	}
+	for _, option := range option {
+		option(self)
+	}
+	return self
+}
+
+// StashDirectory is the (cleaned) directory built files are deposited into
+func (self *Builder) StashDirectory() string {
+	stash := self.Stash
+	if stash != "" {
+		stash = filepath.Clean(stash)
+	}
+	return stash
+}
+
+// Output is the file "go build" will write for platform: [<stash>/]<name>-<os>-<arch>[.exe]
+func (self *Builder) Output(name string, platform Platform) string {
+	output := strings.Join([]string{name, platform.OS, platform.Arch}, "-")
+	if stash := self.StashDirectory(); stash != "" {
+		output = filepath.Join(stash, output)
+	}
+	if self.Exe && platform.OS == "windows" {
+		output += ".exe"
+	}
+	return output
+}
+
+// What to do with the native platform (see Builder.Native)
+const (
+	NativeInclude = "include" // Along with every other platform
+	NativeExclude = "exclude" // Leave the native platform out
+	NativeOnly    = "only"    // Leave every other platform out
+)
+
+// native is target, with or without the native platform (according to Native)
+// By default, the native platform is included, except in a setup of more than one platform (a bulk setup),
+// as $GOROOT is assumed to be ready for it
+func (self *Builder) native(target []Platform, setup bool) []Platform {
+	policy := self.Native
+	if policy == "" {
+		policy = NativeInclude
+		if setup && len(target) > 1 {
+			policy = NativeExclude
+		}
+	}
+	result := []Platform{}
+	for _, platform := range target {
+		native := self.Toolchain.Native(platform)
+		if (policy == NativeExclude && native) || (policy == NativeOnly && !native) {
+			self.Log.Debugf("Skip: %s (native: %s)", platform, policy)
+			continue
+		}
+		result = append(result, platform)
+	}
+	return result
+}
+
+// BuildTarget is target as Build and Go see it: with or without the native platform (according to Native)
+func (self *Builder) BuildTarget(target []Platform) []Platform {
+	return self.native(target, false)
+}
+
+// skipReason is why the platform is skipped by Build or Go (SkipNotSetup, etc.), with any detail, or "" if it is not
+// setup is the result of a first time setup (if there was one)
+func (self *Builder) skipReason(platform Platform, setup Results) (string, string) {
+	if detail := self.Toolchain.cgoUnavailable(platform); detail != "" {
+		return SkipNoCgo, detail
+	}
+	if setup != nil && self.DryRun {
+		// The setup was only printed, so assume it worked
+		return "", ""
+	}
+	readiness, detail := self.Toolchain.Readiness(platform)
+	switch readiness {
+	case Ready:
+		return "", ""
+	case Stale:
+		return SkipStale, detail
+	}
+	for _, result := range setup {
+		if result.Platform == platform && result.Err != nil {
+			return SkipSetupFailed, result.Err.Error()
+		}
+	}
+	return SkipNotSetup, ""
+}
+
+// Build runs "go build -o <output> [arguments]" for each platform of target that is ready (setting up first, if need be)
+// With Module.Download, the modules are downloaded once, before any platform is built
+// Cancelling ctx stops the build, killing whatever is running
+// The error is a failure of the run as a whole (the before-all or after-all hook, go mod download), not of any platform
+func (self *Builder) Build(ctx context.Context, target []Platform, arguments []string) (Results, error) {
+	target = self.native(target, false)
+	setup := self.firstTimeSetup(ctx, target)
+	name, err := self.BuiltName(arguments)
+	if err != nil {
+		self.Log.Warnf("%s", err)
+	}
+
+	stash := self.StashDirectory()
+	if stash != "" && !self.DryRun {
+		os.MkdirAll(stash, 0777) // Ignore error, "go build" will squawk below
+	}
+
+	results := Results{}
+	hook := self.Hook
+	err = self.runHook(ctx, "before-all", hook.BeforeAll, nil, stash)
+	if err != nil {
+		return results, self.contextError(err, ctx, ctx, ctx)
+	}
+
+	err = self.download(ctx)
+	if err != nil {
+		return results, err
+	}
+
+	if self.Strip {
+		arguments = StripArguments(arguments)
+	}
+
+	// With FailFast, the first failure cancels the rest
+	run, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for _, platform := range target {
+		result := Result{
+			Platform: platform,
+			Output:   self.Output(name, platform),
+		}
+		if reason, detail := self.skipReason(platform, setup); reason != "" {
+			self.Log.Skip(platform, reason, detail)
+			result.Skip = reason
+			results = append(results, result)
+			continue
+		}
+		if run.Err() != nil {
+			result.Err = self.contextError(ErrCanceled, ctx, run, run)
+			self.Log.Debugf("Skip: %s (%s)", platform, result.Err)
+			results = append(results, result)
+			continue
+		}
+		self.Log.Start(platform)
+		start := time.Now()
+		platformCtx, cancelPlatform := self.withTimeout(run)
+		err := self.runHook(platformCtx, "before-platform", hook.BeforePlatform, &platform, result.Output)
+		if err == nil {
+			self.Log.Infof("Build: %s", result.Output)
+			result.Attempts, err = self.retry(platformCtx, platform.String(), self.Stdout, self.Stderr, func(stdout, stderr io.Writer) error {
+				cmd, override := self.goCommand(platformCtx, self.Toolchain.Override(platform), append([]string{"build", "-o", self.buildOutput(result.Output)}, arguments...)...)
+				self.Log.Debugf("Run: %s", commandLine(override, cmd))
+				cmd.Stdin = self.Stdin
+				cmd.Stdout = stdout
+				cmd.Stderr = stderr
+				if !self.trace(fmt.Sprintf("%s => %s", platform, result.Output), override, cmd) {
+					return nil
+				}
+				return runCommand(cmd)
+			})
+		}
+		if err == nil && self.Compress {
+			result.Compression, err = self.compress(platformCtx, platform, result.Output)
+		}
+		if err == nil && !self.DryRun {
+			if info, err := os.Stat(result.Output); err == nil {
+				result.Size = info.Size()
+			}
+		}
+		if err == nil {
+			err = self.runHook(platformCtx, "after-platform", hook.AfterPlatform, &platform, result.Output)
+		}
+		result.Err = self.contextError(err, ctx, run, platformCtx)
+		result.Duration = time.Since(start)
+		cancelPlatform()
+		if result.Err != nil {
+			self.Log.Fail(platform, result.Err)
+			if self.FailFast {
+				cancel()
+			}
+		} else {
+			self.Log.Done(platform)
+		}
+		results = append(results, result)
+	}
+	if run.Err() != nil {
+		// Stopping early, so there is nothing for after-all to do
+		return results, nil
+	}
+
+	for _, result := range results {
+		if result.Compression != nil {
+			self.Log.Infof("Size: %s", result.Compression)
+		}
+	}
+
+	err = self.runHook(ctx, "after-all", hook.AfterAll, nil, stash)
+	if err != nil {
+		return results, self.contextError(err, ctx, ctx, ctx)
+	}
+	return results, nil
+}
+
+// Go runs "go [arguments]" for each platform of target that is ready (setting up first, if need be)
+// With Module.Download, the modules are downloaded once first, if the go command builds (go build, go test, etc.)
+// Cancelling ctx stops the run, killing whatever is running
+// The error is a failure of the run as a whole (go mod download), not of any platform
+func (self *Builder) Go(ctx context.Context, target []Platform, arguments []string) (Results, error) {
+	target = self.native(target, false)
+	setup := self.firstTimeSetup(ctx, target)
+	results := Results{}
+	if len(arguments) > 0 && downloadCommand[arguments[0]] {
+		if err := self.download(ctx); err != nil {
+			return results, err
+		}
+	}
+	// With FailFast, the first failure cancels the rest
+	run, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for _, platform := range target {
+		if reason, detail := self.skipReason(platform, setup); reason != "" {
+			self.Log.Skip(platform, reason, detail)
+			results = append(results, Result{
+				Platform: platform,
+				Skip:     reason,
+			})
+			continue
+		}
+		if run.Err() != nil {
+			err := self.contextError(ErrCanceled, ctx, run, run)
+			self.Log.Debugf("Skip: %s (%s)", platform, err)
+			results = append(results, Result{
+				Platform: platform,
+				Err:      err,
+			})
+			continue
+		}
+		self.Log.Start(platform)
+		start := time.Now()
+		platformCtx, cancelPlatform := self.withTimeout(run)
+		attempts, err := self.retry(platformCtx, platform.String(), self.Stdout, self.Stderr, func(stdout, stderr io.Writer) error {
+			cmd, override := self.goCommand(platformCtx, self.Toolchain.Override(platform), arguments...)
+			cmd.Stdin = self.Stdin
+			cmd.Stdout = stdout
+			cmd.Stderr = stderr
+			self.Log.Debugf("Run: %s", commandLine(override, cmd))
+			if !self.trace(platform.String(), override, cmd) {
+				return nil
+			}
+			return runCommand(cmd)
+		})
+		err = self.contextError(err, ctx, run, platformCtx)
+		cancelPlatform()
+		if err != nil {
+			self.Log.Fail(platform, err)
+			if self.FailFast {
+				cancel()
+			}
+		} else {
+			self.Log.Done(platform)
+		}
+		results = append(results, Result{
+			Platform: platform,
+			Attempts: attempts,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+	}
+	return results, nil
+}
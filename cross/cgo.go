@@ -0,0 +1,107 @@
+package cross
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// The platforms cgo supports (used when "go tool dist list -json" is unavailable)
+var cgoSupport = map[string]bool{
+	"darwin/386":      true,
+	"darwin/amd64":    true,
+	"darwin/arm64":    true,
+	"dragonfly/amd64": true,
+	"freebsd/386":     true,
+	"freebsd/amd64":   true,
+	"freebsd/arm":     true,
+	"freebsd/arm64":   true,
+	"linux/386":       true,
+	"linux/amd64":     true,
+	"linux/arm":       true,
+	"linux/arm64":     true,
+	"linux/mips":      true,
+	"linux/mipsle":    true,
+	"linux/mips64":    true,
+	"linux/mips64le":  true,
+	"linux/ppc64le":   true,
+	"linux/riscv64":   true,
+	"linux/s390x":     true,
+	"netbsd/386":      true,
+	"netbsd/amd64":    true,
+	"netbsd/arm":      true,
+	"openbsd/386":     true,
+	"openbsd/amd64":   true,
+	"solaris/amd64":   true,
+	"windows/386":     true,
+	"windows/amd64":   true,
+	"windows/arm64":   true,
+}
+
+// CgoCapable is whether cgo can be used with the platform (according to "go tool dist list -json", if available)
+func (self *Toolchain) CgoCapable(platform Platform) bool {
+	if self.distList == nil {
+		self.distList = map[string]bool{}
+		cmd := exec.Command(self.Go(), "tool", "dist", "list", "-json")
+		cmd.Env = self.Environment()
+		output, err := cmd.Output()
+		if err == nil {
+			list := []struct {
+				GOOS         string
+				GOARCH       string
+				CgoSupported bool
+			}{}
+			if json.Unmarshal(output, &list) == nil {
+				for _, item := range list {
+					self.distList[item.GOOS+"/"+item.GOARCH] = item.CgoSupported
+				}
+			}
+		}
+	}
+	if cgo, exists := self.distList[platform.String()]; exists {
+		return cgo
+	}
+	return cgoSupport[platform.String()]
+}
+
+// Compiler is the C compiler make.bash (and cgo) will use for the platform:
+// $CC_FOR_${GOOS}_${GOARCH}, $CC (if set explicitly, see Env), or the $CC of go (for the native platform)
+func (self *Toolchain) Compiler(platform Platform) string {
+	override := self.Override(platform)
+	if cc := environmentValue(self.Environment(override...), "CC_FOR_"+platform.OS+"_"+platform.Arch); cc != "" {
+		return cc
+	}
+	if cc := environmentValue(override, "CC"); cc != "" {
+		return cc
+	}
+	if self.Native(platform) {
+		return self.GoEnv["CC"]
+	}
+	return ""
+}
+
+// cgoUnavailable is why cgo, though enabled for the platform (CGO_ENABLED=1), will not work, or "" if it will (or is not enabled)
+func (self *Toolchain) cgoUnavailable(platform Platform) string {
+	if environmentValue(self.Override(platform), "CGO_ENABLED") != "1" {
+		return ""
+	}
+	if !self.CgoCapable(platform) {
+		return fmt.Sprintf("cgo is unsupported by %s", platform)
+	}
+	if !self.Native(platform) && self.Compiler(platform) == "" {
+		return fmt.Sprintf("missing C compiler ($CC_FOR_%s_%s)", platform.OS, platform.Arch)
+	}
+	return ""
+}
+
+// environmentValue is the (last) value of key in environment
+func environmentValue(environment []string, key string) string {
+	value := ""
+	for _, entry := range environment {
+		if strings.HasPrefix(entry, key+"=") {
+			value = entry[len(key)+1:]
+		}
+	}
+	return value
+}
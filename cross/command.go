@@ -0,0 +1,142 @@
+package cross
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrCanceled is the error of a platform that was not (or not completely) done, because another failed first (see FailFast)
+	ErrCanceled = errors.New("canceled (fail fast)")
+
+	// ErrTimedOut is the error of a platform that took longer than Timeout (or the context allowed)
+	ErrTimedOut = errors.New("timed out")
+
+	// ErrInterrupted is the error of a platform that was not (or not completely) done, because the context was canceled
+	ErrInterrupted = errors.New("interrupted")
+)
+
+// How long a command has to exit (after SIGTERM, say) before it is killed
+const commandWaitDelay = 5 * time.Second
+
+// command is exec.CommandContext, but in its own process group (see sysproc_*.go)
+// When ctx is done, the whole group is terminated (and then killed, if need be), not just the command
+func command(ctx context.Context, name string, argument ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, argument...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return terminateProcessGroup(cmd, commandWaitDelay)
+	}
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
+
+// runCommand is cmd.Run, but with a terminal for stdin (of which gxc is in the foreground), cmd is put in the foreground,
+// as a process group in the background is stopped when it reads from the terminal (see runForeground)
+func runCommand(cmd *exec.Cmd) error {
+	if terminal := terminalOf(cmd.Stdin); terminal != nil {
+		return runForeground(cmd, terminal)
+	}
+	return cmd.Run()
+}
+
+// terminalOf is input, if it is (probably) a terminal: a character device other than os.DevNull, or nil if it is not
+func terminalOf(input io.Reader) *os.File {
+	file, is := input.(*os.File)
+	if !is || file == nil {
+		return nil
+	}
+	info, err := file.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(info, null) {
+		return nil
+	}
+	return file
+}
+
+// A word that is the same, whether quoted or not
+var matchPlainWord = regexp.MustCompile(`^[0-9A-Za-z_\-+=/.,:@%]+$`)
+
+// shellWord is value, quoted (if need be) to be pasted into a shell
+func shellWord(value string) string {
+	if matchPlainWord.MatchString(value) {
+		return value
+	}
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// trace prints cmd to Print (if any), under a header: its working directory, then its environment override and command line
+// It is whether cmd should actually be run (not with DryRun)
+func (self *Builder) trace(header string, override []string, cmd *exec.Cmd) bool {
+	if self.Print != nil {
+		directory := cmd.Dir
+		if directory == "" {
+			directory, _ = os.Getwd()
+		}
+		line := []string{}
+		for _, value := range override {
+			key, value, _ := strings.Cut(value, "=")
+			line = append(line, key+"="+shellWord(value))
+		}
+		for _, argument := range cmd.Args {
+			line = append(line, shellWord(argument))
+		}
+		self.printLock.Lock()
+		defer self.printLock.Unlock()
+		fmt.Fprintf(self.Print, "# %s\ncd %s\n%s\n", header, shellWord(directory), strings.Join(line, " "))
+	}
+	return !self.DryRun
+}
+
+// withTimeout is ctx, limited to Timeout (if any), for the work on a single platform
+func (self *Builder) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if self.Timeout > 0 {
+		return context.WithTimeout(ctx, self.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// contextError explains err in terms of what stopped it (if anything), from the outside in:
+// parent (the context of the caller), run (canceled with FailFast), and platform (limited to Timeout)
+func (self *Builder) contextError(err error, parent, run, platform context.Context) error {
+	if err == nil || platform.Err() == nil {
+		return err
+	}
+	switch {
+	case parent.Err() == context.DeadlineExceeded:
+		return ErrTimedOut
+	case parent.Err() != nil:
+		return ErrInterrupted
+	case run.Err() != nil:
+		return ErrCanceled
+	case platform.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("%w (after %s)", ErrTimedOut, self.Timeout)
+	}
+	return err
+}
+
+// Status is what happened to the platform: "ok", "skipped", "failed", "timed out", "interrupted", or "canceled"
+func (self Result) Status() string {
+	switch {
+	case self.Err == nil && self.Skip != "":
+		return "skipped"
+	case self.Err == nil:
+		return "ok"
+	case errors.Is(self.Err, ErrTimedOut):
+		return "timed out"
+	case self.Err == ErrInterrupted:
+		return "interrupted"
+	case self.Err == ErrCanceled:
+		return "canceled"
+	}
+	return "failed"
+}
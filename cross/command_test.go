@@ -0,0 +1,25 @@
+package cross
+
+import (
+	"testing"
+)
+
+func TestShellWord(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"go", "go"},
+		{"GOOS=linux", "GOOS=linux"},
+		{"./cmd/xyzzy-1.0_linux,amd64:@%+", "./cmd/xyzzy-1.0_linux,amd64:@%+"},
+		{"", "''"},
+		{"-X main.version=1", "'-X main.version=1'"},
+		{"$HOME", "'$HOME'"},
+		{"it's", `'it'\''s'`},
+	}
+	for _, test := range tests {
+		if have := shellWord(test.value); have != test.want {
+			t.Errorf("shellWord(%q) = %s, want %s", test.value, have, test.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package cross
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// The platforms upx can pack (as of upx 4)
+// (darwin is left out, as upx 4 refuses a Mach-O file without --force-macos, and the result is often unusable)
+var upxSupport = map[string]bool{
+	"linux/386":       true,
+	"linux/amd64":     true,
+	"linux/arm":       true,
+	"linux/arm64":     true,
+	"linux/mips":      true,
+	"linux/mipsle":    true,
+	"linux/ppc64le":   true,
+	"freebsd/386":     true,
+	"freebsd/amd64":   true,
+	"netbsd/386":      true,
+	"netbsd/amd64":    true,
+	"openbsd/386":     true,
+	"openbsd/amd64":   true,
+	"windows/386":     true,
+	"windows/amd64":   true,
+	"dragonfly/amd64": true,
+}
+
+// Compression is the size of a built file, before and after upx
+type Compression struct {
+	Platform Platform
+	Output   string
+	Before   int64
+	After    int64
+	Skip     string // Why compression was skipped, if it was
+}
+
+func (self Compression) String() string {
+	return self.Output + " " + self.Sizes()
+}
+
+// Sizes is the size before and after: 5678 => 1234 (21%), or the size and why compression was skipped: 5678 (upx not found)
+func (self Compression) Sizes() string {
+	if self.Skip != "" {
+		return fmt.Sprintf("%d (%s)", self.Before, self.Skip)
+	}
+	percent := int64(0)
+	if self.Before > 0 {
+		percent = self.After * 100 / self.Before
+	}
+	return fmt.Sprintf("%d => %d (%d%%)", self.Before, self.After, percent)
+}
+
+// StripArguments adds "-s -w" to the -ldflags of a "go build", adding -ldflags if necessary
+func StripArguments(arguments []string) []string {
+	result := make([]string, 0, len(arguments)+1)
+	found := false
+	for index := 0; index < len(arguments); index++ {
+		argument := arguments[index]
+		if argument == "--" {
+			result = append(result, arguments[index:]...)
+			break
+		}
+		name := strings.TrimLeft(argument, "-")
+		switch {
+		case !found && name == "ldflags" && argument != name && index+1 < len(arguments):
+			found = true
+			index += 1
+			result = append(result, argument, strings.TrimSpace(arguments[index]+" -s -w"))
+			continue
+		case !found && strings.HasPrefix(name, "ldflags=") && argument != name:
+			found = true
+			result = append(result, strings.TrimSpace(argument+" -s -w"))
+			continue
+		}
+		result = append(result, argument)
+	}
+	if !found {
+		result = append([]string{"-ldflags=-s -w"}, result...)
+	}
+	return result
+}
+
+// compress runs upx on output, if upx exists and supports the platform
+func (self *Builder) compress(ctx context.Context, platform Platform, output string) (*Compression, error) {
+	result := &Compression{
+		Platform: platform,
+		Output:   output,
+	}
+	if self.DryRun {
+		// Nothing was built, so there is nothing to measure (or compress)
+		if upx, err := exec.LookPath("upx"); err == nil && upxSupport[platform.String()] {
+			self.trace(fmt.Sprintf("%s (compress)", platform), nil, command(ctx, upx, "-q", "-q", output))
+		}
+		return nil, nil
+	}
+	info, err := os.Stat(output)
+	if err != nil {
+		return result, err
+	}
+	result.Before = info.Size()
+	result.After = result.Before
+
+	upx, err := exec.LookPath("upx")
+	if err != nil {
+		result.Skip = "upx not found"
+		return result, nil
+	}
+	if !upxSupport[platform.String()] {
+		result.Skip = "unsupported by upx"
+		return result, nil
+	}
+
+	self.Log.Infof("Compress: %s", output)
+	cmd := command(ctx, upx, "-q", "-q", output)
+	cmd.Stdout = self.Stdout
+	cmd.Stderr = self.Stderr
+	self.trace(fmt.Sprintf("%s (compress)", platform), nil, cmd)
+	err = cmd.Run()
+	if err != nil {
+		return result, fmt.Errorf("upx: %s", err)
+	}
+
+	info, err = os.Stat(output)
+	if err != nil {
+		return result, err
+	}
+	result.After = info.Size()
+	return result, nil
+}
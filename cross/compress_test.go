@@ -0,0 +1,28 @@
+package cross
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStripArguments(t *testing.T) {
+	tests := []struct {
+		arguments []string
+		want      []string
+	}{
+		{nil, []string{"-ldflags=-s -w"}},
+		{[]string{"./cmd/xyzzy"}, []string{"-ldflags=-s -w", "./cmd/xyzzy"}},
+		{[]string{"-ldflags", "-X main.version=1", "."}, []string{"-ldflags", "-X main.version=1 -s -w", "."}},
+		{[]string{"--ldflags=-X main.version=1"}, []string{"--ldflags=-X main.version=1 -s -w"}},
+		{[]string{"-v", "-ldflags=", "."}, []string{"-v", "-ldflags= -s -w", "."}},
+		// Only the first -ldflags is go's
+		{[]string{"-ldflags=-X a=1", "-ldflags=-X b=2"}, []string{"-ldflags=-X a=1 -s -w", "-ldflags=-X b=2"}},
+		// Whatever follows -- is not go's
+		{[]string{".", "--", "-ldflags=-X a=1"}, []string{"-ldflags=-s -w", ".", "--", "-ldflags=-X a=1"}},
+	}
+	for _, test := range tests {
+		if have := StripArguments(test.arguments); !reflect.DeepEqual(have, test.want) {
+			t.Errorf("StripArguments(%q) = %q, want %q", test.arguments, have, test.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Package cross is the library behind gxc: cross-compiling go for a set of platforms.
+
+    import (
+        "github.com/robertkrimen/gxc/cross"
+    )
+
+    toolchain, err := cross.NewToolchain() // From "go env -json"
+    registry, err := cross.NewRegistry(toolchain)
+
+    builder := cross.NewBuilder(toolchain,
+        cross.WithStash("dist"),
+        cross.WithStrip(),
+        cross.WithLog(cross.NewLogger(os.Stderr, cross.LevelInfo)),
+    )
+
+    // Run make.bash (if necessary), then "go build" for linux and windows/386
+    results, err := builder.Build(context.Background(), registry.Query("linux windows/386"), []string{"./cmd/xyzzy"})
+    if err != nil {
+        fmt.Println(err) // A hook, or go mod download
+    }
+    for _, result := range results.Failed() {
+        fmt.Println(result.Platform, result.Err)
+    }
+
+*/
+package cross
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"time"
+)
+
+// Version is the version of gxc, recorded when a platform is setup
+const Version = "0.2"
+
+var (
+	matchPlatformQuery = regexp.MustCompile(`^([0-9a-z*]+)(?:[/\-_]([0-9a-z*]+))?$`)
+	matchBuiltPackage  = regexp.MustCompile(`(?m)^#\s*\n^#\s*(.*)\s*\n^#\s*\n`)
+)
+
+var (
+	platformUnix = _hostPlatform{
+		runGo:     "go",
+		buildAll:  "all.bash",
+		buildMake: "make.bash",
+	}
+
+	platformWindows = _hostPlatform{
+		runGo:     "go.exe",
+		buildAll:  "all.bat",
+		buildMake: "make.bat",
+	}
+
+	hostPlatform = func() _hostPlatform {
+		if runtime.GOOS == "windows" {
+			return platformWindows
+		}
+		return platformUnix
+	}()
+)
+
+type _hostPlatform struct {
+	runGo     string
+	buildAll  string
+	buildMake string
+}
+
+// Platform is a target for go: linux/386, windows/amd64, etc.
+type Platform struct {
+	OS   string // $GOOS
+	Arch string // $GOARCH
+}
+
+func (self Platform) String() string {
+	return self.OS + "/" + self.Arch
+}
+
+// Match is whether the platform is matched by query: "", "*", "all", "linux", "linux/386", "linux-386", "*/arm", etc.
+func (self Platform) Match(query string) bool {
+	switch query {
+	case "", "*", "all":
+		return true
+	}
+	if match := matchPlatformQuery.FindStringSubmatch(query); match != nil {
+		targetOS, targetArch := match[1], match[2]
+		switch targetOS {
+		case "", "*":
+		default:
+			if targetOS != self.OS {
+				return false
+			}
+		}
+		switch targetArch {
+		case "", "*":
+		default:
+			if targetArch != self.Arch {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Result is what happened to a platform (during a setup, build, etc.)
+type Result struct {
+	Platform    Platform
+	Output      string        // The built file (for a build)
+	Size        int64         // The size of the built file (for a build)
+	Compression *Compression  // (For a build with compression)
+	Attempts    int           // How many times the command (make.bash, go build, etc.) was run, more than 1 with a retry
+	Duration    time.Duration // How long the platform took
+	Skip        string        // Why the platform was skipped (SkipNotSetup, etc.), if it was
+	Err         error         // nil if successful (or skipped)
+}
+
+// Why a platform was skipped (see Result.Skip)
+const (
+	SkipNotSetup    = "not setup"       // make.bash has not been run for the platform
+	SkipSetupFailed = "setup failed"    // make.bash failed for the platform (during this build, etc.)
+	SkipStale       = "stale setup"     // make.bash was run for the platform, but by a different go, etc. (see Readiness)
+	SkipNoCgo       = "cgo unavailable" // cgo is enabled for the platform, but unsupported (or there is no C compiler)
+)
+
+type Results []Result
+
+// Skipped is every result that was skipped
+func (self Results) Skipped() Results {
+	skipped := Results{}
+	for _, result := range self {
+		if result.Skip != "" {
+			skipped = append(skipped, result)
+		}
+	}
+	return skipped
+}
+
+// Failed is every result with an error
+func (self Results) Failed() Results {
+	failed := Results{}
+	for _, result := range self {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Environment is the current environment (without $GOOS, $GOARCH, or $CGO_ENABLED), plus override
+func Environment(override ...string) []string {
+	matchExclude := regexp.MustCompile(`^(GO(?:ARCH|OS)|CGO_ENABLED)=`)
+	// This tmp will be the current environment (excluding matchExclude)
+	tmp := []string(nil)
+	for _, value := range os.Environ() {
+		if matchExclude.MatchString(value) {
+			continue
+		}
+		tmp = append(tmp, value)
+	}
+	return append(tmp, override...)
+}
+
+// CacheDirectory is where gxc keeps what it generates: $XDG_CACHE_HOME/gxc, ~/.cache/gxc, etc.
+func CacheDirectory() string {
+	if home := os.Getenv("XDG_CACHE_HOME"); home != "" {
+		return filepath.Join(home, "gxc")
+	}
+	home, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "gxc")
+	}
+	return filepath.Join(home, "gxc")
+}
@@ -0,0 +1,107 @@
+package cross
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefaultAllow is what is inherited from the current environment with Hermetic (see Env)
+// A trailing * matches any variable with that prefix
+var DefaultAllow = []string{
+	// The basics
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "TMPDIR", "LANG", "LC_*",
+	// (Windows)
+	"SYSTEMROOT", "SYSTEMDRIVE", "COMSPEC", "PATHEXT", "TEMP", "TMP", "USERPROFILE", "APPDATA", "LOCALAPPDATA",
+	// Where go (and gxc) are, and keeps things
+	"GOROOT", "GOPATH", "GOCACHE", "GOMODCACHE", "XDG_CACHE_HOME",
+	// How go fetches modules
+	"GOPROXY", "GOSUMDB", "GOPRIVATE", "GONOPROXY", "GONOSUMDB", "GOINSECURE",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+	"SSL_CERT_FILE", "SSL_CERT_DIR",
+}
+
+// Env controls the environment of everything run for a platform (make.bash, go build, hooks, etc.)
+//
+// By default, the current environment is inherited (without $GOOS, $GOARCH, or $CGO_ENABLED, which are set for each platform)
+// With Hermetic, only what is in Allow is inherited, and go does not read its configuration file (GOENV=off),
+// so that GOFLAGS, GOEXPERIMENT, CC, etc. from a shell do not leak into a build
+//
+// Set and Platform are added to (or replace) whatever is inherited, and even what is set for each platform
+type Env struct {
+	Hermetic bool
+	Allow    []string            // What to inherit with Hermetic (DefaultAllow, if nil)
+	Set      []string            // KEY=VALUE, for every platform
+	Platform map[string][]string // KEY=VALUE, for each platform matching the query: "linux/arm": {"GOARM=7"}
+}
+
+// allowed is whether the variable key is inherited
+func (self Env) allowed(key string) bool {
+	allow := self.Allow
+	if allow == nil {
+		allow = DefaultAllow
+	}
+	for _, allow := range allow {
+		if strings.HasSuffix(allow, "*") {
+			if strings.HasPrefix(key, allow[:len(allow)-1]) {
+				return true
+			}
+		} else if key == allow {
+			return true
+		}
+	}
+	return false
+}
+
+// override is Set, plus each of Platform matching platform (in order of query)
+func (self Env) override(platform Platform) []string {
+	override := append([]string(nil), self.Set...)
+	query := []string{}
+	for key := range self.Platform {
+		if platform.Match(key) {
+			query = append(query, key)
+		}
+	}
+	sort.Strings(query)
+	for _, query := range query {
+		override = append(override, self.Platform[query]...)
+	}
+	return override
+}
+
+// Environment is the environment of a command (make.bash, go build, a hook, etc.), plus override (see Env)
+// A variable appears once, with the last value given
+func (self *Toolchain) Environment(override ...string) []string {
+	environment := []string{}
+	if self.Env.Hermetic {
+		for _, value := range os.Environ() {
+			if key, _, _ := strings.Cut(value, "="); self.Env.allowed(key) {
+				environment = append(environment, value)
+			}
+		}
+		environment = append(environment, "GOENV=off")
+	} else {
+		environment = Environment()
+	}
+	// $GOROOT is that of the toolchain (the private copy, with Isolate)
+	environment = append(environment, "GOROOT="+self.Root)
+	return uniqueEnvironment(append(environment, override...))
+}
+
+// uniqueEnvironment is environment with only the last value of each variable (in place of the first)
+func uniqueEnvironment(environment []string) []string {
+	last := map[string]string{}
+	for _, value := range environment {
+		key, _, _ := strings.Cut(value, "=")
+		last[key] = value
+	}
+	result := []string{}
+	for _, value := range environment {
+		key, _, _ := strings.Cut(value, "=")
+		if value, exists := last[key]; exists {
+			result = append(result, value)
+			delete(last, key)
+		}
+	}
+	return result
+}
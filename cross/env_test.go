@@ -0,0 +1,50 @@
+package cross
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvOverride(t *testing.T) {
+	env := Env{
+		Set: []string{"GOFLAGS=-trimpath"},
+		Platform: map[string][]string{
+			"linux/arm": {"GOARM=7"},
+			"linux":     {"CC=gcc"},
+			"windows":   {"CC=x86_64-w64-mingw32-gcc", "GOFLAGS="},
+		},
+	}
+	tests := []struct {
+		platform Platform
+		want     []string
+	}{
+		{Platform{"linux", "arm"}, []string{"GOFLAGS=-trimpath", "CC=gcc", "GOARM=7"}},
+		{Platform{"linux", "amd64"}, []string{"GOFLAGS=-trimpath", "CC=gcc"}},
+		{Platform{"windows", "386"}, []string{"GOFLAGS=-trimpath", "CC=x86_64-w64-mingw32-gcc", "GOFLAGS="}},
+		{Platform{"darwin", "amd64"}, []string{"GOFLAGS=-trimpath"}},
+	}
+	for _, test := range tests {
+		if have := env.override(test.platform); !reflect.DeepEqual(have, test.want) {
+			t.Errorf("override(%s) = %q, want %q", test.platform, have, test.want)
+		}
+	}
+}
+
+func TestUniqueEnvironment(t *testing.T) {
+	tests := []struct {
+		environment []string
+		want        []string
+	}{
+		{nil, []string{}},
+		{[]string{"A=1", "B=2"}, []string{"A=1", "B=2"}},
+		// The last value, in place of the first
+		{[]string{"A=1", "B=2", "A=3"}, []string{"A=3", "B=2"}},
+		{[]string{"A=1", "A=", "B=2=3"}, []string{"A=", "B=2=3"}},
+		{[]string{"A", "A=1"}, []string{"A=1"}},
+	}
+	for _, test := range tests {
+		if have := uniqueEnvironment(test.environment); !reflect.DeepEqual(have, test.want) {
+			t.Errorf("uniqueEnvironment(%q) = %q, want %q", test.environment, have, test.want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package cross
+
+import (
+	"os"
+	"os/exec"
+)
+
+// runForeground is cmd.Run, leaving cmd where it is (in a process group of its own, if there are such here)
+func runForeground(cmd *exec.Cmd, terminal *os.File) error {
+	return cmd.Run()
+}
@@ -0,0 +1,99 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package cross
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// The process group of each command in the foreground (see runForeground) that is still running, the most recent last
+var foreground struct {
+	sync.Mutex
+	group []int
+}
+
+// runForeground runs cmd (still in a process group of its own) in the foreground of terminal, if gxc (or a command of
+// gxc) is in the foreground of it, then gives the terminal back: to another command still running (with -jobs), or to gxc
+// As the terminal sends ^C (SIGINT) or ^\ (SIGQUIT) to cmd alone, gxc then passes it on to itself, to stop the rest
+func runForeground(cmd *exec.Cmd, terminal *os.File) error {
+	fd := int(terminal.Fd())
+	foreground.Lock()
+	if group, err := terminalGroup(fd); err != nil || !isForeground(group) {
+		// gxc is in the background (or the terminal is not its own), so cmd is, too
+		foreground.Unlock()
+		return cmd.Run()
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Foreground: true, Ctty: fd}
+	err := cmd.Start()
+	if err != nil {
+		foreground.Unlock()
+		return err
+	}
+	foreground.group = append(foreground.group, cmd.Process.Pid)
+	foreground.Unlock()
+
+	err = cmd.Wait()
+
+	foreground.Lock()
+	for index, group := range foreground.group {
+		if group == cmd.Process.Pid {
+			foreground.group = append(foreground.group[:index], foreground.group[index+1:]...)
+			break
+		}
+	}
+	group := syscall.Getpgrp()
+	if count := len(foreground.group); count > 0 {
+		group = foreground.group[count-1]
+	}
+	setTerminalGroup(fd, group) // Ignore error, as the terminal may be gone
+	foreground.Unlock()
+
+	if status, is := cmd.ProcessState.Sys().(syscall.WaitStatus); is && status.Signaled() {
+		if received := status.Signal(); received == syscall.SIGINT || received == syscall.SIGQUIT {
+			syscall.Kill(os.Getpid(), received)
+		}
+	}
+	return err
+}
+
+// isForeground is whether group is that of gxc, or of a command of gxc in the foreground
+// (foreground is locked)
+func isForeground(group int) bool {
+	if group == syscall.Getpgrp() {
+		return true
+	}
+	for _, foreground := range foreground.group {
+		if group == foreground {
+			return true
+		}
+	}
+	return false
+}
+
+// terminalGroup is the process group in the foreground of the terminal (fd)
+func terminalGroup(fd int) (int, error) {
+	var group int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&group)))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(group), nil
+}
+
+// setTerminalGroup puts group in the foreground of the terminal (fd)
+func setTerminalGroup(fd int, group int) error {
+	// Taking the terminal from the background would stop gxc (with SIGTTOU), unless that is ignored
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	value := int32(group)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&value)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
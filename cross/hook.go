@@ -0,0 +1,59 @@
+package cross
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/robertkrimen/gxc/kilt"
+)
+
+// Hook is the commands to run around a build, each with $GOOS, $GOARCH, and $OUTPUT set
+type Hook struct {
+	BeforeAll      string `json:"before-all"`
+	BeforePlatform string `json:"before-platform"`
+	AfterPlatform  string `json:"after-platform"`
+	AfterAll       string `json:"after-all"`
+}
+
+// hookCommand splits a hook into a program and its arguments (via kilt.QuoteParse)
+func hookCommand(hook string) []string {
+	arguments := []string{}
+	for _, word := range kilt.QuoteParse(hook) {
+		arguments = append(arguments, word.Value)
+	}
+	return arguments
+}
+
+// runHook runs hook (if any) with GOOS, GOARCH, and OUTPUT in the environment
+// The platform is nil for before-all and after-all, in which case OUTPUT is the stash
+func (self *Builder) runHook(ctx context.Context, name, hook string, platform *Platform, output string) error {
+	arguments := hookCommand(hook)
+	if len(arguments) == 0 {
+		return nil
+	}
+	override := []string{"OUTPUT=" + output}
+	if platform != nil {
+		override = append(override, self.Toolchain.Override(*platform)...)
+	} else {
+		override = append(override, self.Toolchain.Env.Set...)
+	}
+	self.Log.Infof("Hook (%s): %s", name, hook)
+	cmd := command(ctx, arguments[0], arguments[1:]...)
+	cmd.Env = self.Toolchain.Environment(override...)
+	self.Log.Debugf("Run: %s", commandLine(override, cmd))
+	cmd.Stdin = self.Stdin
+	cmd.Stdout = self.Stdout
+	cmd.Stderr = self.Stderr
+	header := name
+	if platform != nil {
+		header = fmt.Sprintf("%s (%s)", platform, name)
+	}
+	if !self.trace(header, override, cmd) {
+		return nil
+	}
+	err := runCommand(cmd)
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package cross
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is how much to log, from LevelError (only errors) to LevelDebug (everything)
+type Level int
+
+const (
+	LevelError Level = iota
+	LevelWarn
+	LevelInfo
+	LevelDebug
+)
+
+func (self Level) String() string {
+	switch self {
+	case LevelError:
+		return "error"
+	case LevelWarn:
+		return "warn"
+	case LevelInfo:
+		return "info"
+	}
+	return "debug"
+}
+
+// Logger writes progress (to stderr, usually) as lines of text or JSON, ignoring anything above its level
+// A nil Logger logs nothing
+//
+// As text, Start is "- linux/386", Done is "+ linux/386", Fail is "! linux/arm: exit status 2", Skip is "~ plan9/386: skipped (not setup)",
+// Errorf and Warnf are "gxc: ...", and Infof and Debugf are "# ..."
+type Logger struct {
+	Writer io.Writer
+	Level  Level
+	JSON   bool // Log each entry as a line of JSON
+	lock   sync.Mutex
+}
+
+func NewLogger(writer io.Writer, level Level) *Logger {
+	return &Logger{
+		Writer: writer,
+		Level:  level,
+	}
+}
+
+// An Entry is what is logged, and is the form of a JSON log line:
+//
+//	{"time":"2014-03-02T15:04:05Z","level":"error","status":"fail","platform":"linux/arm","message":"exit status 2"}
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Level    string    `json:"level"`
+	Status   string    `json:"status,omitempty"` // start, done, fail, or skip (for a platform)
+	Platform string    `json:"platform,omitempty"`
+	Reason   string    `json:"reason,omitempty"` // Why the platform was skipped: "not setup", etc. (see Result.Skip)
+	Message  string    `json:"message,omitempty"`
+}
+
+// Enabled is whether the logger logs anything at level
+func (self *Logger) Enabled(level Level) bool {
+	return self != nil && level <= self.Level
+}
+
+func (self *Logger) log(level Level, entry Entry) {
+	if !self.Enabled(level) {
+		return
+	}
+	entry.Time = time.Now().UTC()
+	entry.Level = level.String()
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.JSON {
+		data, err := json.Marshal(entry)
+		if err == nil {
+			fmt.Fprintf(self.Writer, "%s\n", data)
+		}
+		return
+	}
+	switch {
+	case entry.Status == "start":
+		fmt.Fprintf(self.Writer, "- %s\n", entry.Platform)
+	case entry.Status == "done":
+		fmt.Fprintf(self.Writer, "+ %s\n", entry.Platform)
+	case entry.Status == "fail":
+		fmt.Fprintf(self.Writer, "! %s: %s\n", entry.Platform, entry.Message)
+	case entry.Status == "skip" && entry.Message != "":
+		fmt.Fprintf(self.Writer, "~ %s: skipped (%s: %s)\n", entry.Platform, entry.Reason, entry.Message)
+	case entry.Status == "skip":
+		fmt.Fprintf(self.Writer, "~ %s: skipped (%s)\n", entry.Platform, entry.Reason)
+	case level <= LevelWarn:
+		fmt.Fprintf(self.Writer, "gxc: %s\n", entry.Message)
+	default:
+		fmt.Fprintf(self.Writer, "# %s\n", entry.Message)
+	}
+}
+
+// Start logs (at info) that work on the platform has started
+func (self *Logger) Start(platform Platform) {
+	self.log(LevelInfo, Entry{Status: "start", Platform: platform.String()})
+}
+
+// Done logs (at info) that work on the platform succeeded
+func (self *Logger) Done(platform Platform) {
+	self.log(LevelInfo, Entry{Status: "done", Platform: platform.String()})
+}
+
+// Fail logs (at error) that work on the platform failed
+func (self *Logger) Fail(platform Platform, err error) {
+	self.log(LevelError, Entry{Status: "fail", Platform: platform.String(), Message: err.Error()})
+}
+
+// Skip logs (at warn) that the platform was skipped, for reason (with detail, if any)
+func (self *Logger) Skip(platform Platform, reason, detail string) {
+	self.log(LevelWarn, Entry{Status: "skip", Platform: platform.String(), Reason: reason, Message: detail})
+}
+
+func (self *Logger) Errorf(format string, argument ...interface{}) {
+	self.log(LevelError, Entry{Message: fmt.Sprintf(format, argument...)})
+}
+
+func (self *Logger) Warnf(format string, argument ...interface{}) {
+	self.log(LevelWarn, Entry{Message: fmt.Sprintf(format, argument...)})
+}
+
+func (self *Logger) Infof(format string, argument ...interface{}) {
+	self.log(LevelInfo, Entry{Message: fmt.Sprintf(format, argument...)})
+}
+
+func (self *Logger) Debugf(format string, argument ...interface{}) {
+	self.log(LevelDebug, Entry{Message: fmt.Sprintf(format, argument...)})
+}
+
+// commandLine is cmd as it might be typed (for debugging): GOOS=linux GOARCH=386 CGO_ENABLED=0 go build ...
+func commandLine(override []string, cmd *exec.Cmd) string {
+	return strings.Join(append(append([]string{}, override...), cmd.Args...), " ")
+}
@@ -0,0 +1,94 @@
+package cross
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/robertkrimen/gxc/kilt"
+)
+
+// Marker is the content of ${GOROOT}/pkg/${GOOS}_${GOARCH}/.gxc, recording how (and when) the platform was setup
+type Marker struct {
+	GoVersion string    `json:"go-version"`
+	GoRoot    string    `json:"goroot"` // A hash of $GOROOT and the go version
+	Cgo       string    `json:"cgo"`    // CGO_ENABLED=
+	Date      time.Time `json:"date"`
+	Gxc       string    `json:"gxc"` // For reference only, as a newer gxc does not make a setup stale
+}
+
+type Readiness int
+
+const (
+	Missing Readiness = iota // Not setup
+	Stale                    // Setup, but by a different toolchain, with a different CGO_ENABLED, or by a gxc too old to say
+	Ready
+)
+
+// Hash identifies the toolchain: its location and version
+func (self *Toolchain) Hash() string {
+	return kilt.Sha1([]byte(self.Root + "\x00" + self.FindVersion()))
+}
+
+// ${GOROOT}/pkg/${GOOS}_${GOARCH}/.gxc
+func (self *Toolchain) MarkerFile(platform Platform) string {
+	return filepath.Join(self.Root, "pkg", platform.OS+"_"+platform.Arch, ".gxc")
+}
+
+// currentMarker is what the marker of the platform should be, if it were setup now
+func (self *Toolchain) currentMarker(platform Platform) Marker {
+	return Marker{
+		GoVersion: self.FindVersion(),
+		GoRoot:    self.Hash(),
+		Cgo:       self.CgoFlag(platform),
+		Date:      time.Now().UTC(),
+		Gxc:       Version,
+	}
+}
+
+func (self *Toolchain) writeMarker(platform Platform) error {
+	data, err := json.MarshalIndent(self.currentMarker(platform), "", "    ")
+	if err != nil {
+		return err
+	}
+	return kilt.WriteAtomicFile(self.MarkerFile(platform), bytes.NewReader(append(data, '\n')), 0666)
+}
+
+func (self *Toolchain) ReadMarker(platform Platform) (*Marker, error) {
+	data, err := ioutil.ReadFile(self.MarkerFile(platform))
+	if err != nil {
+		return nil, err
+	}
+	marker := &Marker{}
+	// An empty (or garbled) .gxc is from an older gxc, and is left as an empty marker
+	json.Unmarshal(data, marker)
+	return marker, nil
+}
+
+// Readiness reports whether the platform is setup, and if stale, why
+func (self *Toolchain) Readiness(platform Platform) (Readiness, string) {
+	marker, err := self.ReadMarker(platform)
+	if err != nil {
+		return Missing, ""
+	}
+	current := self.currentMarker(platform)
+	switch {
+	case marker.GoVersion == "":
+		return Stale, "setup by an older gxc"
+	case marker.GoVersion != current.GoVersion:
+		return Stale, fmt.Sprintf("setup by %s", marker.GoVersion)
+	case marker.GoRoot != current.GoRoot:
+		return Stale, "setup by a different $GOROOT"
+	case marker.Cgo != current.Cgo:
+		return Stale, fmt.Sprintf("setup with %s", marker.Cgo)
+	}
+	return Ready, ""
+}
+
+func (self *Toolchain) IsReady(platform Platform) bool {
+	readiness, _ := self.Readiness(platform)
+	return readiness == Ready
+}
@@ -0,0 +1,135 @@
+package cross
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Module is where (and how) go finds the module being built, for Build and Go (see Builder.Module)
+//
+// Like go -C, ModFile and Workspace are relative to Dir
+// Built files are still deposited relative to the current directory (see Stash)
+type Module struct {
+	Dir       string // Run go in this directory ("" for the current directory)
+	ModFile   string // -modfile: use this file in place of go.mod
+	Mod       string // -mod: readonly, vendor, or mod
+	Workspace string // $GOWORK: a go.work file, or "off" to ignore any workspace ("" for whatever go finds)
+	Download  bool   // Run "go mod download" once, before any platform, so that the platforms do not each fetch modules (see downloadCommand)
+}
+
+// The go commands (for Go) that build, and so are worth a "go mod download" first
+// (Not "go env", "go version", "go mod tidy", etc., which should not fail because the modules cannot be fetched)
+var downloadCommand = map[string]bool{
+	"build":   true,
+	"install": true,
+	"run":     true,
+	"test":    true,
+	"vet":     true,
+}
+
+// override is override, plus the environment for the module: -mod and -modfile (added to $GOFLAGS), and $GOWORK
+// (The go command ignores whatever flags in $GOFLAGS it does not know, so this works for "go env", "go vet", etc. too)
+func (self *Builder) moduleOverride(override []string) []string {
+	module := self.Module
+	result := append([]string(nil), override...)
+	flags := []string{}
+	if module.Mod != "" {
+		flags = append(flags, "-mod="+module.Mod)
+	}
+	if module.ModFile != "" {
+		flags = append(flags, "-modfile="+module.ModFile)
+	}
+	if len(flags) > 0 {
+		if goFlags := strings.TrimSpace(environmentValue(self.Toolchain.Environment(override...), "GOFLAGS")); goFlags != "" {
+			flags = append([]string{goFlags}, flags...)
+		}
+		result = append(result, "GOFLAGS="+strings.Join(flags, " "))
+	}
+	if workspace := module.Workspace; workspace != "" {
+		if workspace != "off" && !filepath.IsAbs(workspace) {
+			// go insists on an absolute $GOWORK
+			workspace, _ = filepath.Abs(filepath.Join(module.Dir, workspace))
+		}
+		result = append(result, "GOWORK="+workspace)
+	}
+	return result
+}
+
+// goCommand is go (with arguments), run in Dir with the environment for the module, along with its environment override
+func (self *Builder) goCommand(ctx context.Context, override []string, argument ...string) (*exec.Cmd, []string) {
+	override = self.moduleOverride(override)
+	cmd := command(ctx, self.Toolchain.Go(), argument...)
+	cmd.Dir = self.Module.Dir
+	cmd.Env = self.Toolchain.Environment(override...)
+	return cmd, override
+}
+
+// GoOverride is the environment override of go (build, etc.) for platform, including that of the module
+func (self *Builder) GoOverride(platform Platform) []string {
+	return self.moduleOverride(self.Toolchain.Override(platform))
+}
+
+// BuiltName is Toolchain.BuiltName, for the module (in Dir, with -modfile, etc.)
+func (self *Builder) BuiltName(arguments []string) (string, error) {
+	cmd, _ := self.goCommand(context.Background(), self.Toolchain.Env.Set, append([]string{"build", "-n"}, arguments...)...)
+	return parseBuiltName(cmd)
+}
+
+// buildOutput is output, as given to "go build -o" (absolute with Dir, as go is run there)
+func (self *Builder) buildOutput(output string) string {
+	if self.Module.Dir != "" {
+		if absolute, err := filepath.Abs(output); err == nil {
+			return absolute
+		}
+	}
+	return output
+}
+
+// download runs "go mod download" (with Download), so that the modules are fetched once, before any platform
+// There is nothing to download outside of a module (or workspace), or with -mod=vendor
+func (self *Builder) download(ctx context.Context) error {
+	if !self.Module.Download || self.Module.Mod == "vendor" {
+		return nil
+	}
+	override := self.Toolchain.Env.Set
+	cmd, _ := self.goCommand(ctx, override, "env", "GOMOD", "GOWORK")
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("go env: %v", err)
+	}
+	found := false
+	for _, path := range strings.Fields(string(output)) {
+		// $GOMOD is os.DevNull in module mode without a go.mod
+		if path != os.DevNull && path != "off" {
+			found = true
+		}
+	}
+	if !found {
+		self.Log.Debugf("Skip: go mod download (not in a module)")
+		return nil
+	}
+
+	self.Log.Infof("Download: go mod download")
+	downloadCtx, cancel := self.withTimeout(ctx)
+	defer cancel()
+	_, err = self.retry(downloadCtx, "go mod download", self.Stdout, self.Stderr, func(stdout, stderr io.Writer) error {
+		cmd, override := self.goCommand(downloadCtx, override, "mod", "download")
+		cmd.Stdin = self.Stdin
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		self.Log.Debugf("Run: %s", commandLine(override, cmd))
+		if !self.trace("go mod download", override, cmd) {
+			return nil
+		}
+		return runCommand(cmd)
+	})
+	if err != nil {
+		return self.contextError(fmt.Errorf("go mod download: %v", err), ctx, ctx, downloadCtx)
+	}
+	return nil
+}
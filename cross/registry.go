@@ -0,0 +1,94 @@
+package cross
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Registry is every platform the toolchain can target, along with named groups of them
+type Registry struct {
+	Platforms []Platform
+	Group     map[string]string // A name for a set of platforms: "release" => "linux/amd64 windows/amd64"
+}
+
+// darwin/386
+// darwin/amd64
+// freebsd/386
+// freebsd/amd64
+// linux/386
+// linux/amd64
+// linux/arm
+// windows/386
+// windows/amd64
+
+// NewRegistry finds the platforms of the toolchain, from ${GOROOT}/src/pkg/runtime/defs_${GOOS}_${GOARCH}.h
+func NewRegistry(toolchain *Toolchain) (*Registry, error) {
+	self := &Registry{}
+	file, err := os.Open(filepath.Join(toolchain.Root, "src", "pkg", "runtime"))
+	if err != nil {
+		return self, err
+	}
+	defer file.Close()
+	files, err := file.Readdirnames(-1)
+	for _, name := range files {
+		if strings.HasPrefix(name, "defs_") && strings.HasSuffix(name, ".h") {
+			name = name[5 : len(name)-2] // defs_*.h
+			index := strings.Index(name, "_")
+			self.Platforms = append(self.Platforms, Platform{
+				OS:   name[0:index],
+				Arch: name[index+1:],
+			})
+		}
+	}
+	return self, err
+}
+
+// Query finds the platforms matching query, a list of platforms and groups: "linux windows/386 release"
+func (self *Registry) Query(query string) []Platform {
+	switch query {
+	case "", "all":
+		return self.Platforms
+	}
+	found := []Platform{}
+	for _, query := range strings.Fields(query) {
+		if group, exists := self.Group[query]; exists {
+			// A named group of platforms, which cannot itself name a group
+			for _, query := range strings.Fields(group) {
+				for _, platform := range self.Platforms {
+					if platform.Match(query) {
+						found = append(found, platform)
+					}
+				}
+			}
+			continue
+		}
+		for _, platform := range self.Platforms {
+			if platform.Match(query) {
+				found = append(found, platform)
+			}
+		}
+	}
+	return found
+}
+
+// Match finds the platforms of each leading query, returning them along with the rest
+// e.g. "linux windows/386 xyzzy" => linux/386 linux/amd64 windows/386, "xyzzy"
+func (self *Registry) Match(query []string) ([]Platform, []string) {
+	index := 0
+	match := []Platform{}
+	for _, query := range query {
+		found := self.Query(query)
+		if len(found) == 0 {
+			break
+		}
+		match = append(match, found...)
+		index += 1
+	}
+	if index < len(query) {
+		query = query[index:]
+	} else {
+		query = []string(nil)
+	}
+	return match, query
+}
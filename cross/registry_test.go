@@ -0,0 +1,36 @@
+package cross
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistryQuery(t *testing.T) {
+	registry := &Registry{
+		Platforms: []Platform{{"darwin", "amd64"}, {"linux", "386"}, {"linux", "amd64"}, {"windows", "386"}},
+		Group: map[string]string{
+			"release": "linux/amd64 windows",
+			"nested":  "release",
+		},
+	}
+	tests := []struct {
+		query string
+		want  []Platform
+	}{
+		{"", registry.Platforms},
+		{"all", registry.Platforms},
+		{"linux", []Platform{{"linux", "386"}, {"linux", "amd64"}}},
+		{"*/386", []Platform{{"linux", "386"}, {"windows", "386"}}},
+		{"linux-amd64 darwin", []Platform{{"linux", "amd64"}, {"darwin", "amd64"}}},
+		{"release", []Platform{{"linux", "amd64"}, {"windows", "386"}}},
+		{"darwin release", []Platform{{"darwin", "amd64"}, {"linux", "amd64"}, {"windows", "386"}}},
+		// A group cannot name a group
+		{"nested", []Platform{}},
+		{"xyzzy", []Platform{}},
+	}
+	for _, test := range tests {
+		if have := registry.Query(test.query); !reflect.DeepEqual(have, test.want) {
+			t.Errorf("Query(%q) = %v, want %v", test.query, have, test.want)
+		}
+	}
+}
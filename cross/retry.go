@@ -0,0 +1,89 @@
+package cross
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	retryDelay        = 2 * time.Second  // How long to wait before the first retry (doubling for each after)
+	retryDelayMaximum = 30 * time.Second // (The most to wait before any retry)
+	retryTail         = 64 * 1024        // How much of stdout and stderr to keep, to check for a permanent failure
+)
+
+// matchPermanentFailure is a failure that will not go away by retrying: a compile error, a missing package, a failed test, etc.
+// (Anything else, a module download hiccup, an OOM kill, etc., is considered transient)
+var matchPermanentFailure = regexp.MustCompile(`(?m)^(?:` +
+	`\S+\.(?:go|s|c|h|cc|cpp|m):\d+(?::\d+)?: ` + // file.go:12:34: ...
+	`|--- FAIL: ` +
+	`|.*(?:undefined: |syntax error|cannot find package |no required module provides package |build constraints exclude all Go files)` +
+	`).*$`)
+
+// permanentFailure is the first line of output (stdout and stderr) that shows a failure to be permanent, or "" if there is none
+// (go test reports --- FAIL: to stdout, a compiler to stderr)
+func permanentFailure(output []byte) string {
+	return string(matchPermanentFailure.Find(output))
+}
+
+// retry runs attempt (for name, a platform or otherwise) until it succeeds, up to 1 + Retries times, with a backoff between each
+// Each attempt is passed stdout and stderr (each plus a tail, to check whether a failure is permanent, in which case there is no retry)
+// There is no retry once ctx is done, either
+func (self *Builder) retry(ctx context.Context, name string, stdout, stderr io.Writer, attempt func(stdout, stderr io.Writer) error) (attempts int, err error) {
+	delay := retryDelay
+	for {
+		attempts += 1
+		tail := &_tailWriter{size: retryTail}
+		err = attempt(tail.tee(stdout), tail.tee(stderr))
+		if err == nil || attempts > self.Retries || ctx.Err() != nil {
+			return attempts, err
+		}
+		if permanent := permanentFailure(tail.Bytes()); permanent != "" {
+			self.Log.Debugf("No retry: %s (%s)", name, permanent)
+			return attempts, err
+		}
+		self.Log.Warnf("%s: %s, retrying in %s (attempt %d of %d)", name, err, delay, attempts+1, self.Retries+1)
+		select {
+		case <-ctx.Done():
+			return attempts, err
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > retryDelayMaximum {
+			delay = retryDelayMaximum
+		}
+	}
+}
+
+// _tailWriter keeps the last size bytes written to it
+type _tailWriter struct {
+	size int
+	data []byte
+	lock sync.Mutex
+}
+
+func (self *_tailWriter) Write(data []byte) (int, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.data = append(self.data, data...)
+	if over := len(self.data) - self.size; over > 0 {
+		self.data = append(self.data[:0], self.data[over:]...)
+	}
+	return len(data), nil
+}
+
+// tee is writer (if any), also writing to the tail
+func (self *_tailWriter) tee(writer io.Writer) io.Writer {
+	if writer == nil {
+		return self
+	}
+	return io.MultiWriter(writer, self)
+}
+
+func (self *_tailWriter) Bytes() []byte {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.data
+}
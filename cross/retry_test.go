@@ -0,0 +1,28 @@
+package cross
+
+import (
+	"testing"
+)
+
+func TestPermanentFailure(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"# xyzzy\n./main.go:12:34: undefined: version\n", "./main.go:12:34: undefined: version"},
+		{"runtime/sys_linux_arm.s:5: unexpected EOF\n", "runtime/sys_linux_arm.s:5: unexpected EOF"},
+		{"=== RUN   TestXyzzy\n--- FAIL: TestXyzzy (0.00s)\nFAIL\n", "--- FAIL: TestXyzzy (0.00s)"},
+		{"main.go:3:8: no required module provides package example.com/xyzzy; to add it:\n", "main.go:3:8: no required module provides package example.com/xyzzy; to add it:"},
+		{"can't load package: package xyzzy: cannot find package \"xyzzy\" in any of:\n", "can't load package: package xyzzy: cannot find package \"xyzzy\" in any of:"},
+		{"package xyzzy: build constraints exclude all Go files in /tmp/xyzzy\n", "package xyzzy: build constraints exclude all Go files in /tmp/xyzzy"},
+		// Transient, so worth a retry
+		{"go: example.com/xyzzy@v1.0.0: dial tcp: lookup proxy.golang.org: i/o timeout\n", ""},
+		{"signal: killed\n", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if have := permanentFailure([]byte(test.output)); have != test.want {
+			t.Errorf("permanentFailure(%q) = %q, want %q", test.output, have, test.want)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package cross
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robertkrimen/gxc/kilt"
+)
+
+// A journal records the progress of a setup, so that an interrupted setup can resume where it left off
+// It is specific to the toolchain and the platforms being setup, and is removed once setup finishes
+type _journal struct {
+	path     string
+	done     map[string]bool
+	readOnly bool // (For a dry run, which must leave a setup to resume alone)
+	lock     sync.Mutex
+}
+
+// ${cache}/setup.${hash}.journal
+func openJournal(toolchain *Toolchain, target []Platform) *_journal {
+	name := []string{toolchain.Hash()}
+	for _, platform := range target {
+		name = append(name, platform.String())
+	}
+	sort.Strings(name[1:])
+	self := &_journal{
+		path: filepath.Join(CacheDirectory(), "setup."+kilt.Sha1([]byte(strings.Join(name, " ")))[:12]+".journal"),
+		done: map[string]bool{},
+	}
+	if file, err := os.Open(self.path); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				self.done[line] = true
+			}
+		}
+		file.Close()
+	}
+	return self
+}
+
+// resumed is true if there was an interrupted setup (of the same platforms) to resume
+func (self *_journal) resumed() bool {
+	return len(self.done) > 0
+}
+
+func (self *_journal) isDone(platform Platform) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.done[platform.String()]
+}
+
+// record appends a completed platform to the journal
+func (self *_journal) record(platform Platform) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.done[platform.String()] = true
+	if self.readOnly {
+		return
+	}
+	os.MkdirAll(filepath.Dir(self.path), 0777)
+	file, err := os.OpenFile(self.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return // Not being able to resume is not fatal
+	}
+	fmt.Fprintln(file, platform.String())
+	file.Close()
+}
+
+// finish removes the journal, as there is nothing left to resume
+func (self *_journal) finish() {
+	if self.readOnly {
+		return
+	}
+	os.Remove(self.path)
+}
+
+// reset forgets every completed platform, starting the journal over (for a forced setup)
+func (self *_journal) reset() {
+	self.lock.Lock()
+	self.done = map[string]bool{}
+	self.lock.Unlock()
+	self.finish()
+}
+
+// ${cache}/work/${version}/${slot}
+func (self *Toolchain) workTreeDirectory(slot int) string {
+	return filepath.Join(CacheDirectory(), "work", filepath.Base(self.Directory()), fmt.Sprint(slot))
+}
+
+// workTree is the work tree for slot, copied from $GOROOT if need be
+// A work tree is a copy of $GOROOT, so that make.bash for different platforms can run at the same time
+func (self *Toolchain) workTree(slot int, log *Logger) (string, error) {
+	directory := self.workTreeDirectory(slot)
+	if _, err := os.Stat(directory); err == nil {
+		return directory, nil
+	}
+	log.Infof("Copying %s => %s", self.Root, directory)
+	tmp := directory + ".tmp"
+	os.RemoveAll(tmp)
+	err := copyTree(self.Root, tmp)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return directory, os.Rename(tmp, directory)
+}
+
+// Before go1.5, the compiler, linker, etc. are named for the arch they target: 5g, 5l, ... for arm, 8g, 8l, ... for 386, etc.
+var (
+	matchArchTool = regexp.MustCompile(`^([0-9])[acgl](?:\.exe)?$`)
+	archToolName  = map[string]string{"386": "8", "amd64": "6", "amd64p32": "6", "arm": "5", "ppc64": "9", "ppc64le": "9"}
+)
+
+// installLock has each work tree install into $GOROOT one platform at a time (as platforms of an arch share its tools)
+var installLock sync.Mutex
+
+// installFrom copies ${root}/pkg/${GOOS}_${GOARCH} (from a work tree) into $GOROOT
+// Before go1.5, make.bash for the platform also builds the tools for its arch (5g, 5l, ...), which are copied too
+func (self *Toolchain) installFrom(platform Platform, root string) error {
+	installLock.Lock()
+	defer installLock.Unlock()
+	name := filepath.Join("pkg", platform.OS+"_"+platform.Arch)
+	target := filepath.Join(self.Root, name)
+	err := os.RemoveAll(target)
+	if err != nil {
+		return err
+	}
+	err = copyTree(filepath.Join(root, name), target)
+	if err != nil {
+		return err
+	}
+
+	tool := filepath.Join("pkg", "tool", self.HostOS+"_"+self.HostArch)
+	list, _ := ioutil.ReadDir(filepath.Join(root, tool))
+	for _, info := range list {
+		match := matchArchTool.FindStringSubmatch(info.Name())
+		if match == nil || match[1] != archToolName[platform.Arch] {
+			continue
+		}
+		// (Copied aside, then renamed, so that $GOROOT never has half a compiler)
+		target := filepath.Join(self.Root, tool, info.Name())
+		os.MkdirAll(filepath.Dir(target), 0777) // Ignore error, the copy will squawk below
+		err := copyTree(filepath.Join(root, tool, info.Name()), target+".tmp")
+		if err == nil {
+			err = os.Rename(target+".tmp", target)
+		}
+		if err != nil {
+			os.Remove(target + ".tmp")
+			return err
+		}
+	}
+	return nil
+}
+
+// firstTimeSetup sets up the target, unless at least one platform is ready, and is the results (nil if it did not)
+// (We then assume the user has already tried to setup before, and we do not want to keep trying to run a slow, broken make.bash)
+func (self *Builder) firstTimeSetup(ctx context.Context, target []Platform) Results {
+	for _, platform := range target {
+		if self.Toolchain.IsReady(platform) {
+			return nil
+		}
+	}
+	return self.Setup(ctx, target)
+}
+
+// Setup runs make.bash for each platform of target that is not ready (or every platform, with Force)
+// By default, the native platform is skipped when setting up more than one platform (see Native)
+// Cancelling ctx stops the setup, killing any make.bash in progress
+func (self *Builder) Setup(ctx context.Context, target []Platform) Results {
+	toolchain := self.Toolchain
+
+	pending := self.native(target, true)
+
+	journal := openJournal(toolchain, pending)
+	journal.readOnly = self.DryRun
+	if self.Force {
+		// Everything is setup again, so there is nothing to resume
+		journal.reset()
+	} else if journal.resumed() {
+		self.Log.Infof("Resuming setup (%s)", journal.path)
+	}
+
+	jobs := self.Jobs
+	if jobs > len(pending) {
+		jobs = len(pending)
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+	// With more than one job, each job runs make.bash in its own work tree
+	// (Except for the native platform, which is always setup in $GOROOT, first)
+	root := []string{toolchain.Root}
+	if jobs > 1 {
+		root = nil
+		for slot := 0; slot < jobs; slot++ {
+			if self.DryRun {
+				root = append(root, toolchain.workTreeDirectory(slot))
+				continue
+			}
+			directory, err := toolchain.workTree(slot, self.Log)
+			if err != nil {
+				self.Log.Warnf("unable to prepare work tree: %s", err)
+				break
+			}
+			root = append(root, directory)
+		}
+		if len(root) == 0 {
+			root = []string{toolchain.Root}
+		}
+	}
+
+	// With FailFast, the first failure cancels everything else (killing any make.bash in progress)
+	run, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := Results{}
+	count := 0
+	var lock sync.Mutex
+	setup := func(platform Platform, root string) {
+		lock.Lock()
+		count += 1
+		progress := fmt.Sprintf("%d/%d", count, len(pending))
+		if run.Err() != nil {
+			defer lock.Unlock()
+			err := self.contextError(ErrCanceled, ctx, run, run)
+			self.Log.Debugf("Skip: %s (%s)", platform, err)
+			results = append(results, Result{
+				Platform: platform,
+				Err:      err,
+			})
+			return
+		}
+		lock.Unlock()
+
+		// A platform that was already setup is reported as done (not skipped)
+		done := func() {
+			lock.Lock()
+			defer lock.Unlock()
+			results = append(results, Result{
+				Platform: platform,
+			})
+			self.Log.Done(platform)
+		}
+		if self.Force && !self.DryRun {
+			os.Remove(toolchain.MarkerFile(platform))
+		}
+		// Only a platform that is ready is skipped (the journal may be stale, after clean -markers, say)
+		if toolchain.IsReady(platform) && !(self.Force && self.DryRun) {
+			if journal.isDone(platform) {
+				self.Log.Debugf("Skip: %s (done before setup was interrupted)", platform)
+			} else {
+				journal.record(platform)
+				self.Log.Debugf("Skip: %s (already setup)", platform)
+			}
+			done()
+			return
+		}
+
+		var stdout, stderr io.Writer
+		var log *os.File
+		emit := ""
+		if self.Verbose {
+			emit = "-"
+			stdout = self.Stdout
+			stderr = self.Stderr
+		} else if self.DryRun {
+			emit = "dry run"
+		} else if self.Discard {
+		} else {
+			var err error
+			log, err = CreateLog(platform)
+			if err != nil {
+				self.Log.Warnf("unable to create log: %s", err)
+			} else {
+				defer log.Close()
+				stdout = log
+				stderr = log
+				emit = log.Name()
+			}
+		}
+		self.Log.Start(platform)
+		self.Log.Infof("Building platform (%s): %s (%s)", progress, platform, emit)
+		start := time.Now()
+		platformCtx, cancelPlatform := self.withTimeout(run)
+		attempts, err := self.retry(platformCtx, platform.String(), stdout, stderr, func(stdout, stderr io.Writer) error {
+			if cmd, override := toolchain.compilerCommand(platformCtx, platform, root); !self.trace(platform.String(), override, cmd) {
+				return nil
+			}
+			return toolchain.BuildCompiler(platformCtx, platform, root, self.Stdin, stdout, stderr)
+		})
+		// Killed (if need be), because of a timeout, an interrupt, or another platform failing first
+		err = self.contextError(err, ctx, run, platformCtx)
+		cancelPlatform()
+
+		lock.Lock()
+		defer lock.Unlock()
+		results = append(results, Result{
+			Platform: platform,
+			Attempts: attempts,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+		if err != nil {
+			self.Log.Fail(platform, err)
+			if err == ErrCanceled || err == ErrInterrupted {
+				return
+			}
+			if self.FailFast {
+				cancel()
+			}
+			if log != nil && self.Log.Enabled(LevelInfo) {
+				log.Sync()
+				var tail bytes.Buffer
+				tailFile(&tail, log.Name(), setupLogTail)
+				self.Log.Infof("%s (last %d lines):\n%s", log.Name(), setupLogTail, strings.TrimRight(tail.String(), "\n"))
+			}
+		} else {
+			journal.record(platform)
+			self.Log.Done(platform)
+		}
+	}
+
+	if len(root) > 1 {
+		for _, platform := range pending {
+			if toolchain.Native(platform) {
+				setup(platform, toolchain.Root)
+			}
+		}
+	}
+	queue := make(chan Platform)
+	var wait sync.WaitGroup
+	for _, root := range root {
+		wait.Add(1)
+		go func(root string) {
+			defer wait.Done()
+			for platform := range queue {
+				setup(platform, root)
+			}
+		}(root)
+	}
+	for _, platform := range pending {
+		if len(root) > 1 && toolchain.Native(platform) {
+			continue
+		}
+		queue <- platform
+	}
+	close(queue)
+	wait.Wait()
+
+	// Anything that failed, timed out, or was interrupted (or canceled) is left for the setup to resume
+	if len(results.Failed()) == 0 {
+		journal.finish()
+	}
+	return results
+}
@@ -0,0 +1,78 @@
+package cross
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	setupLogKeep = 5  // The number of make.bash logs to keep for each platform
+	setupLogTail = 20 // The number of lines to show from a failed make.bash log
+)
+
+// ${cache}/log/${GOOS}-${GOARCH}/
+func LogDirectory(platform Platform) string {
+	return filepath.Join(CacheDirectory(), "log", platform.OS+"-"+platform.Arch)
+}
+
+// CreateLog creates a new (timestamped) make.bash log for the platform, removing the oldest
+func CreateLog(platform Platform) (*os.File, error) {
+	directory := LogDirectory(platform)
+	err := os.MkdirAll(directory, 0777)
+	if err != nil {
+		return nil, err
+	}
+	name := "make." + time.Now().Format("20060102-150405.000") + ".log"
+	file, err := os.Create(filepath.Join(directory, name))
+	if err != nil {
+		return nil, err
+	}
+	logs := Logs(platform)
+	for len(logs) > setupLogKeep {
+		os.Remove(logs[0])
+		logs = logs[1:]
+	}
+	return file, nil
+}
+
+// Logs is every make.bash log for the platform, oldest first
+func Logs(platform Platform) []string {
+	logs, _ := filepath.Glob(filepath.Join(LogDirectory(platform), "make.*.log"))
+	sort.Strings(logs)
+	return logs
+}
+
+// LatestLog is the most recent make.bash log for the platform, or "" if there is none
+func LatestLog(platform Platform) string {
+	logs := Logs(platform)
+	if len(logs) == 0 {
+		return ""
+	}
+	return logs[len(logs)-1]
+}
+
+// tailFile writes the last count lines of path to writer
+func tailFile(writer io.Writer, path string, count int) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	lines := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > count {
+			lines = lines[1:]
+		}
+	}
+	for _, line := range lines {
+		fmt.Fprintf(writer, "    %s\n", line)
+	}
+	return scanner.Err()
+}
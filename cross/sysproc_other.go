@@ -0,0 +1,17 @@
+//go:build windows || plan9
+
+package cross
+
+import (
+	"os/exec"
+	"time"
+)
+
+// setProcessGroup does nothing, as there are no (unix) process groups here
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// terminateProcessGroup kills cmd (but not whatever it runs)
+func terminateProcessGroup(cmd *exec.Cmd, delay time.Duration) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,31 @@
+//go:build !windows && !plan9
+
+package cross
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup puts cmd (and whatever it runs) into a process group of its own
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminateProcessGroup sends SIGTERM to the process group of cmd, then SIGKILL after delay (if cmd has yet to exit)
+func terminateProcessGroup(cmd *exec.Cmd, delay time.Duration) error {
+	group := -cmd.Process.Pid
+	err := syscall.Kill(group, syscall.SIGTERM)
+	if err != nil {
+		return err
+	}
+	time.AfterFunc(delay, func() {
+		// Signal reports os.ErrProcessDone once cmd has been waited for (cmd.ProcessState is set),
+		// after which the group may be gone, and its number taken
+		if cmd.Process.Signal(syscall.Signal(0)) == nil {
+			syscall.Kill(group, syscall.SIGKILL) // Anything left over
+		}
+	})
+	return nil
+}
@@ -0,0 +1,282 @@
+package cross
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Toolchain is a go installation ($GOROOT), which make.bash prepares for each platform
+type Toolchain struct {
+	Root     string            // $GOROOT
+	HostOS   string            // $GOHOSTOS
+	HostArch string            // $GOHOSTARCH
+	Version  string            // go1.2.1, devel +..., etc. (see FindVersion)
+	System   string            // The original $GOROOT (when using a private toolchain, see Isolate)
+	Env      Env               // What is inherited, and what is set, for each platform
+	GoEnv    map[string]string // What "go env" reported (GOROOT, GOPATH, CC, etc.)
+
+	NoNativeCgo bool // Disable cgo for the native platform too (CGO_ENABLED=0), so that every build is static
+
+	distList map[string]bool // platform => cgo supported, from "go tool dist list -json" (see CgoCapable)
+}
+
+// NewToolchain finds the toolchain of whatever "go" is in $PATH (via "go env -json", or "go env" for an older go)
+// The environment of the process is left alone: what go reports is kept in GoEnv
+func NewToolchain() (*Toolchain, error) {
+	goEnv := map[string]string{}
+	output, err := exec.Command(hostPlatform.runGo, "env", "-json").Output()
+	if err == nil {
+		err = json.Unmarshal(output, &goEnv)
+	}
+	if err != nil {
+		output, err = exec.Command(hostPlatform.runGo, "env").Output()
+		if err != nil {
+			return nil, err
+		}
+		goEnv = parseGoEnv(output)
+	}
+	if goEnv["GOROOT"] == "" {
+		return nil, fmt.Errorf(`missing Go environment (go env)`)
+	}
+	return &Toolchain{
+		Root:     goEnv["GOROOT"],
+		HostOS:   goEnv["GOHOSTOS"],
+		HostArch: goEnv["GOHOSTARCH"],
+		Version:  goEnv["GOVERSION"],
+		GoEnv:    goEnv,
+	}, nil
+}
+
+// parseGoEnv parses the output of "go env" (without -json), which is one of:
+//
+//	GOARCH='amd64'    (unix, with ' as '\'')
+//	GOARCH="amd64"    (unix, before go1.11 or so)
+//	set GOARCH=amd64  (windows)
+func parseGoEnv(output []byte) map[string]string {
+	goEnv := map[string]string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimRight(line, "\r"), "set "))
+		key, value, found := strings.Cut(line, "=")
+		if !found || key == "" || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if length := len(value); length > 1 {
+			switch {
+			case value[0] == '\'' && value[length-1] == '\'':
+				value = strings.Replace(value[1:length-1], `'\''`, `'`, -1)
+			case value[0] == '"' && value[length-1] == '"':
+				value = value[1 : length-1]
+			}
+		}
+		goEnv[key] = value
+	}
+	return goEnv
+}
+
+// Native is whether platform is the platform of the toolchain itself
+func (self *Toolchain) Native(platform Platform) bool {
+	return platform.OS == self.HostOS && platform.Arch == self.HostArch
+}
+
+// CgoFlag enables cgo for the native platform only (unless NoNativeCgo), as cross-compiling with cgo needs a C cross-compiler
+func (self *Toolchain) CgoFlag(platform Platform) string {
+	if self.Native(platform) && !self.NoNativeCgo {
+		return "CGO_ENABLED=1"
+	}
+	return "CGO_ENABLED=0"
+}
+
+// Override is the environment that makes go target the platform: GOOS=, GOARCH=, and CGO_ENABLED=
+// (Followed by whatever is set explicitly for the platform, see Env)
+func (self *Toolchain) Override(platform Platform) []string {
+	return append([]string{
+		"GOOS=" + platform.OS,
+		"GOARCH=" + platform.Arch,
+		self.CgoFlag(platform),
+	}, self.Env.override(platform)...)
+}
+
+// FindVersion figures out (once) the version of the toolchain
+func (self *Toolchain) FindVersion() string {
+	if self.Version == "" {
+		self.Version = "unknown"
+		if file, err := os.Open(filepath.Join(self.Root, "VERSION")); err == nil {
+			defer file.Close()
+			line, _ := bufio.NewReader(file).ReadString('\n')
+			if line = strings.TrimSpace(line); line != "" {
+				self.Version = line
+				return self.Version
+			}
+		}
+		// go version go1.2.1 linux/amd64
+		cmd := exec.Command(self.Go(), "version")
+		cmd.Env = self.Environment()
+		output, err := cmd.Output()
+		if err == nil {
+			if field := strings.Fields(string(output)); len(field) > 2 {
+				self.Version = field[2]
+			}
+		}
+	}
+	return self.Version
+}
+
+// Go is the go to run: either whatever "go" is in $PATH or the go of the private toolchain
+func (self *Toolchain) Go() string {
+	if self.System != "" {
+		return filepath.Join(self.Root, "bin", hostPlatform.runGo)
+	}
+	return hostPlatform.runGo
+}
+
+// ${cache}/${version}
+func (self *Toolchain) Directory() string {
+	return filepath.Join(CacheDirectory(), strings.Replace(self.FindVersion(), " ", "_", -1))
+}
+
+// Isolate switches the toolchain to a private copy of $GOROOT, making the copy first if necessary
+// make.bash is then run in (and the .gxc files written to) the copy, leaving $GOROOT untouched
+// With dryRun, a copy yet to be made is not, and the toolchain stays $GOROOT (which the copy would be the same as)
+func (self *Toolchain) Isolate(log *Logger, dryRun bool) error {
+	directory := self.Directory()
+	if _, err := os.Stat(directory); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if dryRun {
+			log.Infof("Copying %s => %s (dry run, not copied)", self.Root, directory)
+			return nil
+		}
+		log.Infof("Copying %s => %s", self.Root, directory)
+		err := os.MkdirAll(filepath.Dir(directory), 0777)
+		if err != nil {
+			return err
+		}
+		// Copy into a temporary directory first, so an interrupted copy is not mistaken for a toolchain
+		tmp, err := ioutil.TempDir(filepath.Dir(directory), ".tmp.")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		err = copyTree(self.Root, filepath.Join(tmp, "go"))
+		if err != nil {
+			return err
+		}
+		err = os.Rename(filepath.Join(tmp, "go"), directory)
+		if err != nil {
+			return err
+		}
+	}
+	self.System, self.Root = self.Root, directory
+	return nil
+}
+
+// compilerCommand is make.bash (in root) for the platform, along with its environment override
+func (self *Toolchain) compilerCommand(ctx context.Context, platform Platform, root string) (*exec.Cmd, []string) {
+	override := append([]string{"GOROOT=" + root}, self.Override(platform)...)
+	cmd := command(ctx, filepath.Join(root, "src", hostPlatform.buildMake), "--no-clean")
+	cmd.Dir = filepath.Dir(cmd.Path)
+	cmd.Env = self.Environment(override...)
+	return cmd, override
+}
+
+// BuildCompiler runs make.bash (in root, either $GOROOT or a work tree) for the platform
+// Cancelling ctx kills make.bash (and everything it runs)
+func (self *Toolchain) BuildCompiler(ctx context.Context, platform Platform, root string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+
+	cmd, _ := self.compilerCommand(ctx, platform, root)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := runCommand(cmd)
+	if err != nil {
+		return err
+	}
+	if root != self.Root {
+		err := self.installFrom(platform, root)
+		if err != nil {
+			return err
+		}
+	}
+	return self.writeMarker(platform)
+}
+
+// BuiltName guesses the name of what "go build" (with arguments) will build, via "go build -n"
+// If it cannot, the name is "build" (along with the error)
+func (self *Toolchain) BuiltName(arguments []string) (string, error) {
+	cmd := exec.Command(self.Go(), append([]string{"build", "-n"}, arguments...)...)
+	cmd.Env = self.Environment()
+	return parseBuiltName(cmd)
+}
+
+// parseBuiltName runs cmd ("go build -n ...") and finds the name of what is built in its output
+func parseBuiltName(cmd *exec.Cmd) (string, error) {
+	output, err := cmd.Output()
+	if err != nil {
+		return "build", fmt.Errorf("unable to guess built name: %v", err)
+	}
+	if match := matchBuiltPackage.FindAllSubmatch(output, -1); match != nil {
+		pkg := string(match[len(match)-1][1])
+		return filepath.Base(pkg), nil
+	}
+	return "build", fmt.Errorf("unable to guess built name")
+}
+
+// copyTree copies the directory src to dst, preserving modes, modification times, and symlinks
+// (The modification times matter to go, which uses them to decide what is stale)
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relative)
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			err = os.MkdirAll(target, mode.Perm()|0700)
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case mode.IsRegular():
+			err = copyFile(path, target, mode.Perm()|0600)
+		default:
+			return nil // Skip sockets, devices, etc.
+		}
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	input, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	output, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(output, input)
+	if err != nil {
+		output.Close()
+		return err
+	}
+	return output.Close()
+}
@@ -0,0 +1,24 @@
+package cross
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGoEnv(t *testing.T) {
+	tests := []struct {
+		output string
+		want   map[string]string
+	}{
+		{"GOARCH='amd64'\nGOOS='linux'\n", map[string]string{"GOARCH": "amd64", "GOOS": "linux"}},
+		{"GOFLAGS='-ldflags=-X main.name=it'\\''s'\n", map[string]string{"GOFLAGS": "-ldflags=-X main.name=it's"}},
+		{"GOARCH=\"amd64\"\nGOROOT=\"/usr/local/go\"\n", map[string]string{"GOARCH": "amd64", "GOROOT": "/usr/local/go"}},
+		{"set GOARCH=amd64\r\nset GOFLAGS=\r\nset GOOS=windows\r\n", map[string]string{"GOARCH": "amd64", "GOFLAGS": "", "GOOS": "windows"}},
+		{"# comment\n\nGOOS='linux'\n=xyzzy\nxyzzy\n", map[string]string{"GOOS": "linux"}},
+	}
+	for _, test := range tests {
+		if have := parseGoEnv([]byte(test.output)); !reflect.DeepEqual(have, test.want) {
+			t.Errorf("parseGoEnv(%q) = %v, want %v", test.output, have, test.want)
+		}
+	}
+}
@@ -23,7 +23,7 @@ http://github.com/robertkrimen/gxc/gxc
     gxc go env                                                                 
                                                                                
     # Setup bash aliases                                                       
-    eval `gxc --bashrc`                                                        
+    eval "$(gxc --bashrc)"                                                     
 */
 package gxc
 
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/robertkrimen/gxc/cross"
+)
+
+var (
+	cleanFlag           = flag.NewFlagSet("clean", flag.ExitOnError)
+	cleanFlag_artifacts = false
+	cleanFlag_markers   = false
+	cleanFlag_logs      = false
+	cleanFlag_cache     = false
+	cleanFlag_all       = false
+	cleanFlag_dryRun    = false
+	_                   = func() byte {
+		cleanFlag.BoolVar(&cleanFlag_artifacts, "artifacts", cleanFlag_artifacts, "Remove built files (the default)")
+		cleanFlag.BoolVar(&cleanFlag_markers, "markers", cleanFlag_markers, "Remove setup (.gxc) markers and journals, so setup runs again")
+		cleanFlag.BoolVar(&cleanFlag_logs, "logs", cleanFlag_logs, "Remove make.bash logs")
+		cleanFlag.BoolVar(&cleanFlag_cache, "cache", cleanFlag_cache, "Remove private toolchains, work trees, and setup journals")
+		cleanFlag.BoolVar(&cleanFlag_all, "all", cleanFlag_all, "Remove all of the above")
+		cleanFlag.BoolVar(&cleanFlag_dryRun, "n", cleanFlag_dryRun, "List what would be removed, without removing anything")
+		return 0
+	}()
+)
+
+// cleanPaths is everything clean would remove for the target
+func cleanPaths(target []cross.Platform) []string {
+	paths := []string{}
+	if cleanFlag_artifacts {
+		name := findBuiltName(nil)
+		for _, platform := range target {
+			output := builder.Output(name, platform)
+			paths = append(paths, output)
+			if platform.OS == "windows" && filepath.Ext(output) != ".exe" {
+				paths = append(paths, output+".exe")
+			}
+		}
+	}
+	if cleanFlag_markers {
+		for _, platform := range target {
+			paths = append(paths, toolchain.MarkerFile(platform))
+		}
+	}
+	if cleanFlag_logs {
+		for _, platform := range target {
+			paths = append(paths, cross.LogDirectory(platform))
+		}
+	}
+	if cleanFlag_markers || cleanFlag_cache {
+		// A journal could otherwise outlive the markers it records (see cross.Setup)
+		journal, _ := filepath.Glob(filepath.Join(cross.CacheDirectory(), "setup.*.journal"))
+		paths = append(paths, journal...)
+	}
+	if cleanFlag_cache {
+		cache := cross.CacheDirectory()
+		paths = append(paths, filepath.Join(cache, "work"))
+		if toolchain.System == "" {
+			// (With -isolate, do not pull the toolchain out from under ourselves)
+			paths = append(paths, toolchain.Directory())
+		}
+	}
+	return paths
+}
+
+func doClean(target []cross.Platform, arguments []string) error {
+	cleanFlag.Parse(arguments)
+	arguments = cleanFlag.Args()
+	if len(arguments) > 0 {
+		// e.g. $ gxc clean -markers windows linux-amd64
+		target = nil
+		for _, query := range arguments {
+			target = append(target, registry.Query(query)...)
+		}
+	}
+	if cleanFlag_all {
+		cleanFlag_artifacts = true
+		cleanFlag_markers = true
+		cleanFlag_logs = true
+		cleanFlag_cache = true
+	}
+	if !cleanFlag_artifacts && !cleanFlag_markers && !cleanFlag_logs && !cleanFlag_cache {
+		cleanFlag_artifacts = true
+	}
+
+	for _, path := range cleanPaths(target) {
+		if _, err := os.Lstat(path); err != nil {
+			continue // Nothing to remove
+		}
+		if cleanFlag_dryRun || *flag_dryRun {
+			fmt.Fprintf(os.Stdout, "%s\n", path)
+			continue
+		}
+		err := os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Remove: %s", path)
+	}
+	return nil
+}
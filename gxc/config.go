@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/robertkrimen/gxc/cross"
+)
+
+// gxc.json
+//
+//	{
+//	    "target": "linux windows darwin/amd64",
+//	    "group": {
+//	        "release": "linux/amd64 linux/arm windows/amd64 darwin/amd64"
+//	    },
+//	    "isolate": true,
+//	    "native": "exclude",
+//	    "hook": {
+//	        "before-all": "go generate ./...",
+//	        "after-platform": "./sign.sh --key release"
+//	    },
+//	    "size": {
+//	        "baseline": "size.json",
+//	        "budget": 5
+//	    },
+//	    "env": {
+//	        "hermetic": true,
+//	        "set": {"GOFLAGS": "-trimpath"},
+//	        "platform": {"linux/arm": {"GOARM": "7"}}
+//	    },
+//	    "module": {
+//	        "dir": "cmd/xyzzy",
+//	        "mod": "vendor"
+//	    }
+//	}
+type _config struct {
+	Target    string            `json:"target"`     // The default for -target
+	Group     map[string]string `json:"group"`      // A name for a set of platforms: -target=release
+	Isolate   bool              `json:"isolate"`    // Like -isolate
+	Native    string            `json:"native"`     // Like -native
+	NativeCgo *bool             `json:"native-cgo"` // Like -native-cgo
+	Hook      cross.Hook        `json:"hook"`
+	Size      _sizeConfig       `json:"size"`
+	Env       _envConfig        `json:"env"`
+	Module    _moduleConfig     `json:"module"`
+}
+
+type _sizeConfig struct {
+	Baseline string  `json:"baseline"`
+	Budget   float64 `json:"budget"` // A percentage
+}
+
+var config _config
+
+// loadConfig reads the configuration at path into config
+// A missing file is only an error if required is true
+func loadConfig(path string, required bool) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return nil
+		}
+		return err
+	}
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return nil
+}
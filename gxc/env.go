@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/robertkrimen/gxc/cross"
+)
+
+// _listFlag is a flag that can be repeated: -env A=1 -env B=2
+type _listFlag []string
+
+func (self *_listFlag) String() string {
+	return strings.Join(*self, " ")
+}
+
+func (self *_listFlag) Set(value string) error {
+	*self = append(*self, value)
+	return nil
+}
+
+//	"env": {
+//	    "hermetic": true,
+//	    "allow": ["SSH_AUTH_SOCK"],
+//	    "set": {"GOFLAGS": "-trimpath"},
+//	    "platform": {
+//	        "linux/arm": {"GOARM": "7"},
+//	        "darwin": {"CGO_ENABLED": "1"}
+//	    }
+//	}
+type _envConfig struct {
+	Hermetic bool                         `json:"hermetic"` // Like -hermetic
+	Allow    []string                     `json:"allow"`    // In addition to the default allowlist
+	Set      map[string]string            `json:"set"`      // For every platform
+	Platform map[string]map[string]string `json:"platform"` // For each platform matching the query (linux, linux/arm, */arm, etc.)
+}
+
+// keyValue is each of variable as KEY=VALUE (ordered by key)
+func keyValue(variable map[string]string) []string {
+	result := []string{}
+	for key, value := range variable {
+		result = append(result, key+"="+value)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// buildEnv is the environment control for a build, from the configuration and the command line (which comes last)
+func buildEnv() (cross.Env, error) {
+	env := cross.Env{
+		Hermetic: config.Env.Hermetic || *flag_hermetic,
+		Set:      keyValue(config.Env.Set),
+		Platform: map[string][]string{},
+	}
+	if allow := append(append([]string(nil), config.Env.Allow...), flag_allow...); len(allow) > 0 {
+		env.Allow = append(append([]string(nil), cross.DefaultAllow...), allow...)
+	}
+	for _, value := range flag_env {
+		if !strings.Contains(value, "=") || strings.HasPrefix(value, "=") {
+			return env, usageError("invalid -env (not KEY=VALUE): %s", value)
+		}
+		env.Set = append(env.Set, value)
+	}
+	for query, variable := range config.Env.Platform {
+		env.Platform[query] = keyValue(variable)
+	}
+	return env, nil
+}
+
+// doEnv prints the environment that a build would use for each platform of target (including -mod, -workfile, etc.)
+func doEnv(target []cross.Platform, arguments []string) error {
+	if len(arguments) > 0 {
+		// e.g. $ gxc env linux/arm
+		target = nil
+		for _, query := range arguments {
+			target = append(target, registry.Query(query)...)
+		}
+	}
+	if len(target) == 0 {
+		return usageError("missing platform: env <platform>")
+	}
+	for index, platform := range target {
+		if len(target) > 1 {
+			if index > 0 {
+				fmt.Fprintln(os.Stdout)
+			}
+			fmt.Fprintf(os.Stdout, "# %s\n", platform)
+		}
+		for _, value := range toolchain.Environment(builder.GoOverride(platform)...) {
+			fmt.Fprintln(os.Stdout, value)
+		}
+	}
+	return nil
+}
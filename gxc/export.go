@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robertkrimen/gxc/cross"
+)
+
+var exportList = []string{"makefile", "ninja", "gha-matrix", "json"}
+
+// What export knows about building each platform
+type _exportTarget struct {
+	Platform string   `json:"platform"`
+	OS       string   `json:"goos"`
+	Arch     string   `json:"goarch"`
+	Env      []string `json:"env"` // GOOS=, GOARCH=, CGO_ENABLED=, ... (with GOWORK= relative to the current directory)
+	Output   string   `json:"output"`
+	Command  []string `json:"command"` // go [-C <directory>] build -o <output> ...
+}
+
+// shellCommand is the target as a (quoted) command line: GOOS=linux ... go build -o ...
+// The go command is given as is (unquoted), so it can be something like $(GO)
+func (self _exportTarget) shellCommand(goCommand string) string {
+	command := []string{}
+	for _, value := range self.Env {
+		// go insists on an absolute $GOWORK, so it is made so when run
+		if workspace := strings.TrimPrefix(value, "GOWORK="); workspace != value && workspace != "off" && !filepath.IsAbs(workspace) {
+			command = append(command, `GOWORK="$PWD"/`+quotePosix(workspace))
+			continue
+		}
+		// Only the value is quoted, as a quoted assignment is not an assignment
+		if index := strings.Index(value, "="); index > 0 {
+			command = append(command, value[:index+1]+quotePosix(value[index+1:]))
+		} else {
+			command = append(command, quotePosix(value))
+		}
+	}
+	command = append(command, goCommand)
+	for _, value := range self.Command[1:] {
+		command = append(command, quotePosix(value))
+	}
+	return strings.Join(command, " ")
+}
+
+func exportTargets(target []cross.Platform, arguments []string) []_exportTarget {
+	if builder.Strip {
+		arguments = cross.StripArguments(arguments)
+	}
+	name := findBuiltName(arguments)
+	current, _ := os.Getwd()
+	list := []_exportTarget{}
+	// The same platforms as build (-native=exclude, etc.)
+	for _, platform := range builder.BuildTarget(target) {
+		output := builder.Output(name, platform)
+		command := []string{"go", "build", "-o", output}
+		if directory := builder.Module.Dir; directory != "" {
+			// With go -C, a relative output is relative to the directory
+			relative := output
+			if !filepath.IsAbs(output) {
+				from, _ := filepath.Abs(directory)
+				to, _ := filepath.Abs(output)
+				relative, _ = filepath.Rel(from, to)
+			}
+			command = []string{"go", "-C", directory, "build", "-o", relative}
+		}
+		env := builder.GoOverride(platform)
+		for index, value := range env {
+			// An absolute $GOWORK is specific to this machine (see shellCommand)
+			if workspace := strings.TrimPrefix(value, "GOWORK="); workspace != value && filepath.IsAbs(workspace) {
+				if relative, err := filepath.Rel(current, workspace); err == nil {
+					env[index] = "GOWORK=" + filepath.ToSlash(relative)
+				}
+			}
+		}
+		list = append(list, _exportTarget{
+			Platform: platform.String(),
+			OS:       platform.OS,
+			Arch:     platform.Arch,
+			Env:      env,
+			Output:   output,
+			Command:  append(command, arguments...),
+		})
+	}
+	return list
+}
+
+func doExport(target []cross.Platform, format string, arguments []string) error {
+	list := exportTargets(target, arguments)
+	switch format {
+	case "makefile", "make":
+		exportMakefile(os.Stdout, list)
+	case "ninja":
+		exportNinja(os.Stdout, list)
+	case "gha-matrix":
+		include := []map[string]string{}
+		for _, target := range list {
+			include = append(include, map[string]string{
+				"platform": target.Platform,
+				"goos":     target.OS,
+				"goarch":   target.Arch,
+				"output":   target.Output,
+				"command":  target.shellCommand("go"),
+			})
+		}
+		data, err := json.Marshal(map[string]interface{}{"include": include})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "%s\n", data)
+	case "json":
+		data, err := json.MarshalIndent(list, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "%s\n", data)
+	default:
+		return usageError("invalid format: %s (%s)", format, strings.Join(exportList, ", "))
+	}
+	return nil
+}
+
+func exportMakefile(writer io.Writer, list []_exportTarget) {
+	// $ is special to make, so it is doubled
+	escape := func(value string) string {
+		return strings.Replace(value, "$", "$$", -1)
+	}
+	output := []string{}
+	for _, target := range list {
+		output = append(output, escape(target.Output))
+	}
+
+	fmt.Fprintf(writer, kilt.GraveTrim(`
+# Generated by: gxc export makefile
+GO ?= go
+
+.PHONY: all clean %s
+
+all: %s
+
+    `), strings.Join(output, " "), strings.Join(output, " "))
+	for index, target := range list {
+		command := strings.Replace(escape(target.shellCommand("\x00")), "\x00", "$(GO)", 1)
+		fmt.Fprintf(writer, "# %s\n%s:\n\t%s\n\n", target.Platform, output[index], command)
+	}
+	fmt.Fprintf(writer, "clean:\n\trm -f %s\n", strings.Join(output, " "))
+}
+
+func exportNinja(writer io.Writer, list []_exportTarget) {
+	// $, space, and : are special to ninja (in paths), and are escaped with $
+	escape := func(value string) string {
+		value = strings.Replace(value, "$", "$$", -1)
+		value = strings.Replace(value, " ", "$ ", -1)
+		return strings.Replace(value, ":", "$:", -1)
+	}
+	output := []string{}
+	for _, target := range list {
+		output = append(output, escape(target.Output))
+	}
+
+	fmt.Fprint(writer, kilt.GraveTrim(`
+# Generated by: gxc export ninja
+go = go
+
+rule go-build
+  command = $command
+  description = Build $out
+
+# Always run go build, which knows best whether anything is stale
+build always: phony
+
+    `))
+	for index, target := range list {
+		command := strings.Replace(target.shellCommand("\x00"), "$", "$$", -1)
+		command = strings.Replace(command, "\x00", "$go", 1)
+		fmt.Fprintf(writer, "# %s\nbuild %s: go-build | always\n  command = %s\n\n", target.Platform, output[index], command)
+	}
+	fmt.Fprintf(writer, "default %s\n", strings.Join(output, " "))
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"debug/plan9obj"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robertkrimen/gxc/cross"
+)
+
+// builtPlatform finds the platform a built file is named for (<name>-<os>-<arch>[.exe])
+func builtPlatform(target []cross.Platform, filename string) (cross.Platform, bool) {
+	name := strings.TrimSuffix(filename, ".exe")
+	for _, platform := range target {
+		if strings.HasSuffix(name, "-"+platform.OS+"-"+platform.Arch) {
+			return platform, true
+		}
+	}
+	return cross.Platform{}, false
+}
+
+// executableFormat opens path as an executable, returning the format and architecture ($GOARCH)
+// The operating system is only known for some formats, and is "" otherwise
+func executableFormat(path string) (format string, major string, minor string, err error) {
+	if file, err := elf.Open(path); err == nil {
+		defer file.Close()
+		major := ""
+		switch {
+		case file.OSABI == elf.ELFOSABI_FREEBSD:
+			major = "freebsd"
+		case file.Section(".note.netbsd.ident") != nil:
+			major = "netbsd"
+		case file.Section(".note.openbsd.ident") != nil:
+			major = "openbsd"
+		}
+		little := file.ByteOrder == binary.LittleEndian
+		minor := ""
+		switch file.Machine {
+		case elf.EM_386:
+			minor = "386"
+		case elf.EM_X86_64:
+			minor = "amd64"
+		case elf.EM_ARM:
+			minor = "arm"
+		case elf.EM_AARCH64:
+			minor = "arm64"
+		case elf.EM_MIPS:
+			minor = "mips"
+			if file.Class == elf.ELFCLASS64 {
+				minor = "mips64"
+			}
+			if little {
+				minor += "le"
+			}
+		case elf.EM_PPC64:
+			minor = "ppc64"
+			if little {
+				minor += "le"
+			}
+		case elf.EM_S390:
+			minor = "s390x"
+		case elf.EM_RISCV:
+			minor = "riscv64"
+		case elf.EM_LOONGARCH:
+			minor = "loong64"
+		default:
+			minor = file.Machine.String()
+		}
+		return "elf", major, minor, nil
+	}
+
+	if file, err := pe.Open(path); err == nil {
+		defer file.Close()
+		minor := ""
+		switch file.Machine {
+		case pe.IMAGE_FILE_MACHINE_I386:
+			minor = "386"
+		case pe.IMAGE_FILE_MACHINE_AMD64:
+			minor = "amd64"
+		case pe.IMAGE_FILE_MACHINE_ARMNT:
+			minor = "arm"
+		case pe.IMAGE_FILE_MACHINE_ARM64:
+			minor = "arm64"
+		default:
+			minor = fmt.Sprintf("%#x", file.Machine)
+		}
+		return "pe", "windows", minor, nil
+	}
+
+	if file, err := macho.Open(path); err == nil {
+		defer file.Close()
+		minor := ""
+		switch file.Cpu {
+		case macho.Cpu386:
+			minor = "386"
+		case macho.CpuAmd64:
+			minor = "amd64"
+		case macho.CpuArm:
+			minor = "arm"
+		case macho.CpuArm64:
+			minor = "arm64"
+		default:
+			minor = file.Cpu.String()
+		}
+		return "macho", "darwin", minor, nil
+	}
+
+	if file, err := plan9obj.Open(path); err == nil {
+		defer file.Close()
+		minor := ""
+		switch file.Magic {
+		case plan9obj.Magic386:
+			minor = "386"
+		case plan9obj.MagicAMD64:
+			minor = "amd64"
+		case plan9obj.MagicARM:
+			minor = "arm"
+		default:
+			minor = fmt.Sprintf("%#x", file.Magic)
+		}
+		return "plan9obj", "plan9", minor, nil
+	}
+
+	return "", "", "", fmt.Errorf("unrecognized executable format")
+}
+
+// inspectFile checks that path was built for platform, returning a description of what was found
+func inspectFile(platform cross.Platform, path string) (found string, info *buildinfo.BuildInfo, err error) {
+	format, major, minor, err := executableFormat(path)
+	if err != nil {
+		return "", nil, err
+	}
+	info, _ = buildinfo.ReadFile(path) // Not every executable is from Go
+	settingMinor := ""
+	if info != nil {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "GOOS":
+				if major == "" || (major == "darwin" && setting.Value == "ios") {
+					major = setting.Value
+				}
+			case "GOARCH":
+				settingMinor = setting.Value
+			}
+		}
+	}
+	if major == "" {
+		major = "?"
+	}
+	found = major + "/" + minor + " (" + format + ")"
+	if major == "?" && format == "elf" {
+		// An ELF without any identifying mark, likely linux (or android, solaris, ...)
+		major = platform.OS
+	}
+	if major != platform.OS || minor != platform.Arch {
+		return found, info, fmt.Errorf("expected %s, found %s", platform, found)
+	}
+	if settingMinor != "" && settingMinor != minor {
+		return found, info, fmt.Errorf("%s, but built with GOARCH=%s", found, settingMinor)
+	}
+	return found, info, nil
+}
+
+func doInspect(target []cross.Platform, arguments []string) (results cross.Results, err error) {
+	directory := builder.StashDirectory()
+	if len(arguments) > 0 {
+		directory = arguments[0]
+	}
+	if directory == "" {
+		directory = "."
+	}
+
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		platform, exists := builtPlatform(target, file.Name())
+		if !exists {
+			continue
+		}
+		path := filepath.Join(directory, file.Name())
+		found, info, err := inspectFile(platform, path)
+		results = append(results, cross.Result{
+			Platform: platform,
+			Output:   path,
+			Err:      err,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stdout, "! %s: %s\n", path, err)
+		} else {
+			fmt.Fprintf(os.Stdout, "+ %s: %s\n", path, found)
+		}
+		if info != nil {
+			for _, line := range strings.Split(strings.TrimSpace(info.String()), "\n") {
+				fmt.Fprintf(os.Stdout, "    %s\n", line)
+			}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,11 @@
+//go:build !plan9
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// The signals that stop gxc (see interruptContext)
+var interruptSignal = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// The signals that stop gxc (see interruptContext), as there is no SIGQUIT here
+var interruptSignal = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
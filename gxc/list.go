@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/robertkrimen/gxc/cross"
+)
+
+var (
+	listFlag        = flag.NewFlagSet("list", flag.ExitOnError)
+	listFlag_format = ""
+	listFlag_json   = false
+	_               = func() byte {
+		listFlag.StringVar(&listFlag_format, "format", listFlag_format, "Print each platform using this template (e.g. {{.Platform}} {{.CC}})")
+		listFlag.BoolVar(&listFlag_json, "json", listFlag_json, "Print the platforms as JSON")
+		return 0
+	}()
+)
+
+// The first-class ports, see https://go.dev/wiki/PortingPolicy
+var firstClass = map[string]bool{
+	"darwin/amd64":  true,
+	"darwin/arm64":  true,
+	"linux/386":     true,
+	"linux/amd64":   true,
+	"linux/arm":     true,
+	"linux/arm64":   true,
+	"windows/386":   true,
+	"windows/amd64": true,
+}
+
+// The variants of an architecture, selected by an environment variable: GOARM=5, GOAMD64=v3, etc.
+var archVariant = map[string]string{
+	"386":      "GO386=sse2,softfloat",
+	"amd64":    "GOAMD64=v1,v2,v3,v4",
+	"arm":      "GOARM=5,6,7",
+	"arm64":    "GOARM64=v8.0..v9.5",
+	"mips":     "GOMIPS=hardfloat,softfloat",
+	"mipsle":   "GOMIPS=hardfloat,softfloat",
+	"mips64":   "GOMIPS64=hardfloat,softfloat",
+	"mips64le": "GOMIPS64=hardfloat,softfloat",
+	"ppc64":    "GOPPC64=power8,power9,power10",
+	"ppc64le":  "GOPPC64=power8,power9,power10",
+	"riscv64":  "GORISCV64=rva20u64,rva22u64",
+	"wasm":     "GOWASM=satconv,signext",
+}
+
+// What list shows for each platform (for -format and -json)
+type _platformInfo struct {
+	Platform   string `json:"platform"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	Status     string `json:"status"` // ready, stale, or missing
+	Reason     string `json:"reason,omitempty"`
+	Native     bool   `json:"native"`
+	Cgo        bool   `json:"cgo"`
+	FirstClass bool   `json:"first-class"`
+	Variant    string `json:"variant,omitempty"` // GOARM=5,6,7
+	CC         string `json:"cc,omitempty"`
+}
+
+func platformInfo(platform cross.Platform) _platformInfo {
+	info := _platformInfo{
+		Platform:   platform.String(),
+		OS:         platform.OS,
+		Arch:       platform.Arch,
+		Native:     toolchain.Native(platform),
+		Cgo:        toolchain.CgoCapable(platform),
+		FirstClass: firstClass[platform.String()],
+		CC:         toolchain.Compiler(platform),
+	}
+	readiness, reason := toolchain.Readiness(platform)
+	switch readiness {
+	case cross.Ready:
+		info.Status = "ready"
+	case cross.Stale:
+		info.Status = "stale"
+		info.Reason = reason
+	default:
+		info.Status = "missing"
+	}
+	info.Variant = archVariant[platform.Arch]
+	return info
+}
+
+func doList(target []cross.Platform, arguments []string) error {
+	listFlag.Parse(arguments)
+	arguments = listFlag.Args()
+	if len(arguments) > 0 {
+		// e.g. $ gxc list windows linux-amd64
+		target = nil
+		for _, query := range arguments {
+			target = append(target, registry.Query(query)...)
+		}
+	}
+	list := []_platformInfo{}
+	for _, platform := range target {
+		list = append(list, platformInfo(platform))
+	}
+
+	if listFlag_json {
+		data, err := json.MarshalIndent(list, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "%s\n", data)
+		return nil
+	}
+
+	if listFlag_format != "" {
+		format, err := template.New("").Parse(listFlag_format)
+		if err != nil {
+			return err
+		}
+		for _, info := range list {
+			err := format.Execute(os.Stdout, info)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stdout)
+		}
+		return nil
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, info := range list {
+		status := "-"
+		switch info.Status {
+		case "ready":
+			status = "+"
+		case "stale":
+			status = "~"
+		}
+		column := []string{status + " " + info.Platform, "-", "-", "-", "-", "-"}
+		if info.Native {
+			column[1] = "native"
+		}
+		if info.Cgo {
+			column[2] = "cgo"
+		}
+		if info.FirstClass {
+			column[3] = "first-class"
+		}
+		if info.Variant != "" {
+			column[4] = info.Variant
+		}
+		if info.CC != "" {
+			column[5] = info.CC
+		}
+		if info.Reason != "" {
+			column = append(column, "("+info.Reason+")")
+		}
+		fmt.Fprintln(table, strings.Join(column, "\t"))
+	}
+	return table.Flush()
+}
@@ -14,34 +14,167 @@ Install
 
      go get github.com/robertkrimen/gxc/gxc
 
+Library
+
+gxc is a thin wrapper around the package cross (the platforms, setup, build, etc.),
+which can be used to cross-compile without gxc:
+
+     import "github.com/robertkrimen/gxc/cross"
+
 Usage
 
      Usage: gxc ...                                                                     
                                                                                         
+         -C="": Run go (build, go, etc.) in this directory, like go -C (built files still go to -stash, from here)
+         -after-all="": A command to run after building every platform                  
+         -after-platform="": A command to run after building each platform              
+         -allow=: Also inherit this variable with -hermetic (LC_* for a prefix), can be repeated
          -bashrc=false: Emit bash aliases: go-all, go-build-all, go-linux-386, ...      
+         -before-all="": A command to run before building any platform                  
+         -before-platform="": A command to run before building each platform            
+         -compress=false: Compress built files with upx (if available and supported)    
+         -config="gxc.json": The configuration file to read (if it exists)              
+         -download=true: Run go mod download once, before build (or go build, test, vet, etc.) runs for each platform (unless -mod=vendor)
+         -env=: Set KEY=VALUE for every platform (make.bash, go build, hooks), can be repeated
          -exe=false: Add an .exe extension to files built for windows/*                 
+         -fail-fast=false: Stop at the first platform to fail (cancelling any in progress)
+         -hermetic=false: Inherit only an allowlist of the environment (PATH, HOME, GOPATH, ...), see -allow
+         -isolate=false: Setup and build with a private copy of $GOROOT (leaving $GOROOT untouched)
+         -keep-going=false: Keep going after a platform fails (the default)             
+         -log-json=false: Log as lines of JSON (to stderr)                              
+         -mod="": How go treats go.mod and vendor: readonly, vendor, or mod (like go -mod)
+         -modfile="": Use this file in place of go.mod (like go -modfile, relative to -C)
+         -n=false: Print the commands (make.bash, go build, hooks, etc.) for each platform, without running them
+         -native="": What to do with the native platform: include, exclude, or only (by default, include, except in a bulk setup)
+         -native-cgo=true: Enable cgo for the native platform (-native-cgo=false builds it with CGO_ENABLED=0, like every other)
+         -q=false: Log only errors                                                      
+         -require-all=false: Fail if any platform is skipped (not setup, setup failed, stale setup, or cgo unavailable)
+         -retries=0: Retry a failed make.bash, go build, etc. this many times (unless it is a compile error, etc.)
          -stash="": Directory to deposit built files into                               
-         -target="": The platforms to target (linux, windows/386, etc.)                 
+         -strip=false: Strip built files of symbols and DWARF (-ldflags "-s -w")        
+         -summary-md="": Append a summary table (Markdown) of build, go, or setup to this file (- for stdout)
+         -target="": The platforms to target (linux, windows/386, a configured group, etc.)
+         -timeout=0s: The most time to spend on each platform (e.g. 10m), 0 is no limit 
+         -timeout-all=0s: The most time to spend on every platform, altogether (e.g. 1h)
+         -v=false: Log more: commands, hooks, skipped platforms, etc.                   
+         -workfile="": The go.work file of a workspace (relative to -C), or off to ignore any ($GOWORK)
+         -x=false: Print the commands (make.bash, go build, hooks, etc.) for each platform, as they run
+                                                                                        
+       list [options] [platform]                                                        
+         List available platforms and status (+ ready, - not setup, ~ stale)            
+         Along with whether each is native, supports cgo, is first-class, variants, and $CC_FOR_*
                                                                                         
-       list                                                                             
-         List available platforms and status                                            
+         -format="": Print each platform using this template (e.g. {{.Platform}} {{.CC}})
+         -json=false: Print the platforms as JSON                                       
                                                                                         
        build [options]                                                                  
          Run "go build -o <name> [options]" for each platform                           
          The name is of the format <command/package>-<platform>                         
          Options are passed through to "go build"                                       
+         Hooks (-before-all, ...) are run with $GOOS, $GOARCH, and $OUTPUT set          
                                                                                         
        go [options]                                                                     
          Run "go [options]" for each platform                                           
          Options are passed through to "go"                                             
                                                                                         
+       env [platform]                                                                   
+         Print the environment of a build (make.bash, go build, hooks) for the specified platform
+         With -hermetic, only PATH, HOME, GOPATH, etc. (and -allow) are inherited, and GOENV=off
+         -env and the configuration ("env": {"set": ..., "platform": ...}) set variables explicitly
+                                                                                        
        setup [options] [platform]                                                       
          Run make.bash for the specified platform (or every platform if none given)     
+         The output of make.bash is logged to ~/.cache/gxc/log                          
+         An interrupted setup resumes where it left off                                 
                                                                                         
          -force=false: Force make.bash to run, even if it already has                   
+         -jobs=1: Run make.bash for this many platforms at once (each in a copy of $GOROOT)
          -quiet=false: Quiet make.bash (redirect stdout/stderr > nil)                   
          -verbose=false: Pass make.bash output to stdout/stderr (instead of logging)    
                                                                                         
+       logs [platform]                                                                  
+         Show the latest make.bash log for the specified platform                       
+                                                                                        
+       clean [options] [platform]                                                       
+         Remove built files, setup markers, logs, and/or caches for the specified platform
+                                                                                        
+         -all=false: Remove all of the above                                            
+         -artifacts=false: Remove built files (the default)                             
+         -cache=false: Remove private toolchains, work trees, and setup journals        
+         -logs=false: Remove make.bash logs                                             
+         -markers=false: Remove setup (.gxc) markers and journals, so setup runs again  
+         -n=false: List what would be removed, without removing anything                
+                                                                                        
+       export <format> [options] [platform]                                             
+         Print a static build description (for building without gxc) for the specified platform
+         The format is makefile, ninja, gha-matrix, or json                             
+         Options are passed through to "go build" (as with build)                       
+                                                                                        
+       shell-init [options] <shell>                                                     
+         Emit functions (go-build-all, go-linux-386, ...) and completion for bash, zsh, fish, or powershell
+         The functions target every platform in $GXC_TARGET (initially -target, or the configured target)
+                                                                                        
+         -commands=false: Also emit go-setup-all, go-check-all (go vet), and go-test-all
+         -groups=false: Also emit functions for each configured group: go-<group>, go-build-<group>
+                                                                                        
+       completion <shell>                                                               
+         Emit only the completion for bash, zsh, fish, or powershell                    
+                                                                                        
+       size [options] [package]                                                         
+         List the size of the built file for each platform (named as in build)          
+         Optionally compare against (or save) a baseline, failing when over budget      
+                                                                                        
+         -baseline="": A baseline file (JSON) to compare against                        
+         -budget=0: Fail when a platform grows by more than this percentage             
+         -save=false: Write the current sizes to the baseline file                      
+                                                                                        
+       inspect [directory]                                                              
+         Check that each built file (in the directory or stash) is for its platform     
+         Print the build information (go version, modules, settings) embedded in each   
+                                                                                        
+       Modules                                                                          
+         -C runs go (build, go, go mod download, etc.) in another directory, with -modfile, -mod, and -workfile passed along
+         (-mod and -modfile by way of $GOFLAGS, and -workfile as $GOWORK, which chooses a go.work workspace, or turns it off)
+         go mod download runs once, before build (or go build, test, vet, etc.) runs for each platform, so they do not each fetch modules
+                                                                                        
+       Native platform                                                                  
+         -native=exclude leaves the native platform out of build, go, setup, and export, and -native=only leaves out every other
+         -native=include sets up the native platform even in a bulk setup (which otherwise assumes $GOROOT is ready for it)
+         -native-cgo=false builds the native platform with CGO_ENABLED=0, so that every build is static
+         (A platform setup with a different CGO_ENABLED is stale, and needs to be setup again)
+                                                                                        
+       Skipped                                                                          
+         build and go skip a platform that is not setup, whose setup failed or is stale, or that needs cgo and cannot have it
+         Each skip is reported (~ plan9/386: skipped (not setup)), with its reason in -log-json, and -require-all fails on any
+         Skipping every platform is a failure, even without -require-all, as nothing was done at all
+                                                                                        
+       Summary                                                                          
+         build, go, and setup end with a table of each platform: status, duration, and the built file (and its size)
+         -summary-md appends the same table, as Markdown, to a file (for release notes, $GITHUB_STEP_SUMMARY, etc.)
+                                                                                        
+       Dry run                                                                          
+         -n prints what build, go, and setup would run for each platform, without running anything (-x prints as they run):
+         a header (the platform, and what is built), the working directory, then the environment override and command line
+         clean (like clean -n) lists what it would remove, and size -save leaves the baseline file alone
+                                                                                        
+       Retries                                                                          
+         -retries reruns a failed make.bash, go build, or go command, waiting longer before each retry (2s, 4s, ...)
+         A compile error, missing package, or failed test is not retried (a download hiccup, OOM kill, etc. is)
+         Any platform that was retried is listed (with its number of attempts) at the end
+                                                                                        
+       Timeouts                                                                         
+         -timeout limits each platform (make.bash, or hooks and go build, etc.), -timeout-all limits the run
+         On a timeout, SIGINT, SIGTERM, SIGHUP, or SIGQUIT, whatever is running is terminated (with its children)
+         The failure summary lists the platforms that timed out, were interrupted, or were canceled separately
+                                                                                        
+       Exit status                                                                      
+         0: Success                                                                     
+         1: Failure (of anything but a platform: -before-all, go mod download, etc.)    
+         2: Usage (an invalid command, option, etc.)                                    
+         3: Environment (go is missing, the configuration is broken, etc.)              
+         4: Partial failure (of some platforms, but not every one)                      
+         5: Total failure (of every platform)                                           
+                                                                                        
            # Build the current command/package for every platform                       
            gxc build                                                                    
                                                                                         
@@ -58,7 +191,12 @@ Usage
            gxc go env                                                                   
                                                                                         
            # Setup bash aliases                                                         
-           eval `gxc --bashrc`                                                          
+           eval "$(gxc --bashrc)"                                                       
+                                                                                        
+           # Setup zsh, fish, or PowerShell functions and completion                    
+           eval "$(gxc shell-init zsh)"                                                 
+           gxc shell-init fish | source                                                 
+           gxc shell-init powershell | Out-String | Invoke-Expression                   
 */
 package main
 
@@ -66,60 +204,109 @@ package main
 // TODO Emit results as JSON for machine consumption?
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"regexp"
-	"runtime"
 	"strings"
+	"time"
+
+	"github.com/robertkrimen/gxc/cross"
 )
 
 var (
-	goRoot      = ""
-	goHostMajor = ""
-	goHostMinor = ""
+	logger    *cross.Logger
+	toolchain *cross.Toolchain
+	registry  *cross.Registry
+	builder   *cross.Builder
 )
 
 var (
-	matchKeyValue        = regexp.MustCompile(`(?m)^(?:set )?([^=]+)=(.*)$`)
-	matchQuote           = regexp.MustCompile(`^(?:"(.*)")|(?:'(.*)')`)
-	matchPlatformQuery   = regexp.MustCompile(`^([0-9a-z*]+)(?:[/\-_]([0-9a-z*]+))?$`)
-	matchCompoundCommand = regexp.MustCompile(`^(setup|build|go)-([0-9a-z\-]+)$`)
-	matchBuiltPackage    = regexp.MustCompile(`(?m)^#\s*\n^#\s*(.*)\s*\n^#\s*\n`)
+	matchCompoundCommand = regexp.MustCompile(`^(setup|build|go|size|logs|clean|export|env)-([0-9a-z\-]+)$`)
 )
 
 var (
-	platformUnix = _hostPlatform{
-		runGo:     "go",
-		buildAll:  "all.bash",
-		buildMake: "make.bash",
-	}
+	flag_target   = flag.String("target", "", "The platforms to target (linux, windows/386, a configured group, etc.)")
+	flag_bashrc   = flag.Bool("bashrc", false, "Emit bash aliases: go-all, go-build-all, go-linux-386, ...")
+	flag_exe      = flag.Bool("exe", false, "Add an .exe extension to files built for windows/*")
+	flag_stash    = flag.String("stash", "", "Directory to deposit built files into")
+	flag_config   = flag.String("config", "gxc.json", "The configuration file to read (if it exists)")
+	flag_strip    = flag.Bool("strip", false, `Strip built files of symbols and DWARF (-ldflags "-s -w")`)
+	flag_compress = flag.Bool("compress", false, "Compress built files with upx (if available and supported)")
+	flag_isolate  = flag.Bool("isolate", false, "Setup and build with a private copy of $GOROOT (leaving $GOROOT untouched)")
+	flag_verbose  = flag.Bool("v", false, "Log more: commands, hooks, skipped platforms, etc.")
+	flag_quiet    = flag.Bool("q", false, "Log only errors")
+	flag_logJSON  = flag.Bool("log-json", false, "Log as lines of JSON (to stderr)")
+)
 
-	platformWindows = _hostPlatform{
-		runGo:     "go.exe",
-		buildAll:  "all.bat",
-		buildMake: "make.bat",
-	}
+var (
+	flag_failFast   = flag.Bool("fail-fast", false, "Stop at the first platform to fail (cancelling any in progress)")
+	flag_keepGoing  = flag.Bool("keep-going", false, "Keep going after a platform fails (the default)")
+	flag_timeout    = flag.Duration("timeout", 0, "The most time to spend on each platform (e.g. 10m), 0 is no limit")
+	flag_timeoutAll = flag.Duration("timeout-all", 0, "The most time to spend on every platform, altogether (e.g. 1h)")
+	flag_dryRun     = flag.Bool("n", false, "Print the commands (make.bash, go build, hooks, etc.) for each platform, without running them")
+	flag_print      = flag.Bool("x", false, "Print the commands (make.bash, go build, hooks, etc.) for each platform, as they run")
+	flag_native     = flag.String("native", "", "What to do with the native platform: include, exclude, or only (by default, include, except in a bulk setup)")
+	flag_nativeCgo  = flag.Bool("native-cgo", true, "Enable cgo for the native platform (-native-cgo=false builds it with CGO_ENABLED=0, like every other)")
+	flag_requireAll = flag.Bool("require-all", false, "Fail if any platform is skipped (not setup, setup failed, stale setup, or cgo unavailable)")
+	flag_summaryMD  = flag.String("summary-md", "", "Append a summary table (Markdown) of build, go, or setup to this file (- for stdout)")
+	flag_retries    = flag.Int("retries", 0, "Retry a failed make.bash, go build, etc. this many times (unless it is a compile error, etc.)")
+)
 
-	hostPlatform = platformUnix
+var (
+	flag_hermetic = flag.Bool("hermetic", false, "Inherit only an allowlist of the environment (PATH, HOME, GOPATH, ...), see -allow")
+	flag_allow    = _listFlag{}
+	flag_env      = _listFlag{}
+	_             = func() byte {
+		flag.Var(&flag_allow, "allow", "Also inherit this variable with -hermetic (LC_* for a prefix), can be repeated")
+		flag.Var(&flag_env, "env", "Set KEY=VALUE for every platform (make.bash, go build, hooks), can be repeated")
+		return 0
+	}()
+)
+
+var (
+	flag_dir      = flag.String("C", "", "Run go (build, go, etc.) in this directory, like go -C (built files still go to -stash, from here)")
+	flag_modFile  = flag.String("modfile", "", "Use this file in place of go.mod (like go -modfile, relative to -C)")
+	flag_mod      = flag.String("mod", "", "How go treats go.mod and vendor: readonly, vendor, or mod (like go -mod)")
+	flag_workFile = flag.String("workfile", "", "The go.work file of a workspace (relative to -C), or off to ignore any ($GOWORK)")
+	flag_download = flag.Bool("download", true, "Run go mod download once, before build (or go build, test, vet, etc.) runs for each platform (unless -mod=vendor)")
+)
+
+// The exit status of gxc
+const (
+	exitFailure     = 1 // Something failed (-before-all, go mod download, etc.) other than a platform
+	exitUsage       = 2 // An invalid command, option, etc.
+	exitEnvironment = 3 // go is missing, the configuration is broken, etc.
+	exitPartial     = 4 // Some platforms failed, but not every one
+	exitTotal       = 5 // Every platform failed (or was skipped)
 )
 
-type _hostPlatform struct {
-	runGo     string
-	buildAll  string
-	buildMake string
+// An error that is a particular exit status
+type _exitError struct {
+	status int
+	err    error
+}
+
+func (self _exitError) Error() string {
+	return self.err.Error()
+}
+
+func usageError(format string, argument ...interface{}) error {
+	return _exitError{exitUsage, fmt.Errorf(format, argument...)}
+}
+
+func environmentError(err error) error {
+	return _exitError{exitEnvironment, err}
 }
 
 var (
-	flag_target = flag.String("target", "", "The platforms to target (linux, windows/386, etc.)")
-	flag_bashrc = flag.Bool("bashrc", false, "Emit bash aliases: go-all, go-build-all, go-linux-386, ...")
-	flag_exe    = flag.Bool("exe", false, "Add an .exe extension to files built for windows/*")
-	flag_stash  = flag.String("stash", "", "Directory to deposit built files into")
-	flag_quiet  = false // _GXC_QUIET
+	flag_beforeAll      = flag.String("before-all", "", "A command to run before building any platform")
+	flag_beforePlatform = flag.String("before-platform", "", "A command to run before building each platform")
+	flag_afterPlatform  = flag.String("after-platform", "", "A command to run after building each platform")
+	flag_afterAll       = flag.String("after-all", "", "A command to run after building every platform")
 )
 
 var (
@@ -127,13 +314,16 @@ var (
 	setupFlag_force   = false
 	setupFlag_verbose = false
 	setupFlag_quiet   = false
+	setupFlag_jobs    = 1
 	_                 = func() byte {
 		setupFlag.BoolVar(&setupFlag_force, "force", setupFlag_force, "Force make.bash to run, even if it already has")
-		setupFlag.BoolVar(&setupFlag_force, "f", setupFlag_force, string(0))
+		setupFlag.BoolVar(&setupFlag_force, "f", setupFlag_force, "\x00")
 		setupFlag.BoolVar(&setupFlag_verbose, "verbose", setupFlag_verbose, "Pass make.bash output to stdout/stderr (instead of logging)")
-		setupFlag.BoolVar(&setupFlag_verbose, "v", setupFlag_verbose, string(0))
+		setupFlag.BoolVar(&setupFlag_verbose, "v", setupFlag_verbose, "\x00")
 		setupFlag.BoolVar(&setupFlag_quiet, "quiet", setupFlag_quiet, "Quiet make.bash (redirect stdout/stderr > nil)")
-		setupFlag.BoolVar(&setupFlag_quiet, "q", setupFlag_quiet, string(0))
+		setupFlag.BoolVar(&setupFlag_quiet, "q", setupFlag_quiet, "\x00")
+		setupFlag.IntVar(&setupFlag_jobs, "jobs", setupFlag_jobs, "Run make.bash for this many platforms at once (each in a copy of $GOROOT)")
+		setupFlag.IntVar(&setupFlag_jobs, "j", setupFlag_jobs, "\x00") // Hidden (see kilt.PrintDefaults)
 		return 0
 	}()
 )
@@ -142,27 +332,131 @@ func usage() {
 	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 	flag.PrintDefaults()
 
-	fmt.Fprintf(os.Stderr, kilt.GraveTrim(`
+	fmt.Fprint(os.Stderr, kilt.GraveTrim(`
 
- list
-  List available platforms and status
+ list [options] [platform]
+  List available platforms and status (+ ready, - not setup, ~ stale)
+  Along with whether each is native, supports cgo, is first-class, variants, and $CC_FOR_*
+
+    `))
+	kilt.PrintDefaults(listFlag)
+
+	fmt.Fprintf(os.Stderr, kilt.GraveTrim(`
 
  build [options]
   Run "go build -o <name> [options]" for each platform
   The name is of the format <command/package>-<platform>
   Options are passed through to "go build"
+  Hooks (-before-all, ...) are run with $GOOS, $GOARCH, and $OUTPUT set
 
  go [options]
   Run "go [options]" for each platform
   Options are passed through to "go"
+
+ env [platform]
+  Print the environment of a build (make.bash, go build, hooks) for the specified platform
+  With -hermetic, only PATH, HOME, GOPATH, etc. (and -allow) are inherited, and GOENV=off
+  -env and the configuration ("env": {"set": ..., "platform": ...}) set variables explicitly
     
  setup [options] [platform]
   Run make.bash for the specified platform (or every platform if none given)
+  The output of make.bash is logged to %s
+  An interrupted setup resumes where it left off
 
-    `))
+    `), filepath.Join(cross.CacheDirectory(), "log"))
 	kilt.PrintDefaults(setupFlag)
 
-	fmt.Fprintf(os.Stderr, kilt.GraveTrim(`
+	fmt.Fprint(os.Stderr, kilt.GraveTrim(`
+
+ logs [platform]
+  Show the latest make.bash log for the specified platform
+
+ clean [options] [platform]
+  Remove built files, setup markers, logs, and/or caches for the specified platform
+
+    `))
+	kilt.PrintDefaults(cleanFlag)
+
+	fmt.Fprint(os.Stderr, kilt.GraveTrim(`
+
+ export <format> [options] [platform]
+  Print a static build description (for building without gxc) for the specified platform
+  The format is makefile, ninja, gha-matrix, or json
+  Options are passed through to "go build" (as with build)
+
+ shell-init [options] <shell>
+  Emit functions (go-build-all, go-linux-386, ...) and completion for bash, zsh, fish, or powershell
+  The functions target every platform in $GXC_TARGET (initially -target, or the configured target)
+
+    `))
+	kilt.PrintDefaults(shellFlag)
+
+	fmt.Fprint(os.Stderr, kilt.GraveTrim(`
+
+ completion <shell>
+  Emit only the completion for bash, zsh, fish, or powershell
+    `))
+
+	fmt.Fprint(os.Stderr, kilt.GraveTrim(`
+
+ size [options] [package]
+  List the size of the built file for each platform (named as in build)
+  Optionally compare against (or save) a baseline, failing when over budget
+
+    `))
+	kilt.PrintDefaults(sizeFlag)
+
+	fmt.Fprint(os.Stderr, kilt.GraveTrim(`
+
+ inspect [directory]
+  Check that each built file (in the directory or stash) is for its platform
+  Print the build information (go version, modules, settings) embedded in each
+
+ Modules
+  -C runs go (build, go, go mod download, etc.) in another directory, with -modfile, -mod, and -workfile passed along
+  (-mod and -modfile by way of $GOFLAGS, and -workfile as $GOWORK, which chooses a go.work workspace, or turns it off)
+  go mod download runs once, before build (or go build, test, vet, etc.) runs for each platform, so they do not each fetch modules
+
+ Native platform
+  -native=exclude leaves the native platform out of build, go, setup, and export, and -native=only leaves out every other
+  -native=include sets up the native platform even in a bulk setup (which otherwise assumes $GOROOT is ready for it)
+  -native-cgo=false builds the native platform with CGO_ENABLED=0, so that every build is static
+  (A platform setup with a different CGO_ENABLED is stale, and needs to be setup again)
+
+ Skipped
+  build and go skip a platform that is not setup, whose setup failed or is stale, or that needs cgo and cannot have it
+  Each skip is reported (~ plan9/386: skipped (not setup)), with its reason in -log-json, and -require-all fails on any
+  Skipping every platform is a failure, even without -require-all, as nothing was done at all
+
+ Summary
+  build, go, and setup end with a table of each platform: status, duration, and the built file (and its size)
+  -summary-md appends the same table, as Markdown, to a file (for release notes, $GITHUB_STEP_SUMMARY, etc.)
+
+ Dry run
+  -n prints what build, go, and setup would run for each platform, without running anything (-x prints as they run):
+  a header (the platform, and what is built), the working directory, then the environment override and command line
+  clean (like clean -n) lists what it would remove, and size -save leaves the baseline file alone
+
+ Retries
+  -retries reruns a failed make.bash, go build, or go command, waiting longer before each retry (2s, 4s, ...)
+  A compile error, missing package, or failed test is not retried (a download hiccup, OOM kill, etc. is)
+  Any platform that was retried is listed (with its number of attempts) at the end
+
+ Timeouts
+  -timeout limits each platform (make.bash, or hooks and go build, etc.), -timeout-all limits the run
+  On a timeout, SIGINT, SIGTERM, SIGHUP, or SIGQUIT, whatever is running is terminated (with its children)
+  The failure summary lists the platforms that timed out, were interrupted, or were canceled separately
+
+ Exit status
+  0: Success
+  1: Failure (of anything but a platform: -before-all, go mod download, etc.)
+  2: Usage (an invalid command, option, etc.)
+  3: Environment (go is missing, the configuration is broken, etc.)
+  4: Partial failure (of some platforms, but not every one)
+  5: Total failure (of every platform)
+    `))
+
+	fmt.Fprint(os.Stderr, kilt.GraveTrim(`
 
     # Build the current command/package for every platform
     gxc build  
@@ -180,9 +474,14 @@ func usage() {
     gxc go env
 
     # Setup bash aliases
-    eval %s
+    eval "$(gxc --bashrc)"
+
+    # Setup zsh, fish, or PowerShell functions and completion
+    eval "$(gxc shell-init zsh)"
+    gxc shell-init fish | source
+    gxc shell-init powershell | Out-String | Invoke-Expression
 
-    `), "`gxc --bashrc`")
+    `))
 }
 
 // go/src/pkg/runtime/defs*.h
@@ -272,409 +571,224 @@ func usage() {
 //
 // unset -f go-alias
 
-func environment(override ...string) []string {
-	matchExclude := regexp.MustCompile(`^(GO(?:ARCH|OS)|CGO_ENABLED)=`)
-	// This tmp will be the current environment (excluding matchExclude)
-	tmp := []string(nil)
-	for _, value := range os.Environ() {
-		if matchExclude.MatchString(value) {
-			continue
+func doSetup(ctx context.Context, target []cross.Platform, arguments []string) cross.Results {
+	setupFlag.Parse(arguments)
+	arguments = setupFlag.Args()
+	if len(arguments) > 0 {
+		// e.g. $ gxc setup windows linux-amd64 freebsd-386
+		target = nil
+		for _, query := range arguments {
+			target = append(target, registry.Query(query)...)
 		}
-		tmp = append(tmp, value)
 	}
-	return append(tmp, override...)
+	builder.Force = setupFlag_force
+	builder.Jobs = setupFlag_jobs
+	builder.Verbose = setupFlag_verbose
+	builder.Discard = setupFlag_quiet
+	return builder.Setup(ctx, target)
 }
 
-type _platform struct {
-	major string // Operating System ($GOOS)
-	minor string // Architecture ($GOARCH)
-}
-
-type _failure struct {
-	platform _platform
+// findBuiltName is the name of what "go build" (with arguments) will build, complaining if it has to guess
+func findBuiltName(arguments []string) string {
+	name, err := builder.BuiltName(arguments)
+	if err != nil {
+		logger.Warnf("%s", err)
+	}
+	return name
 }
 
-func (self _platform) String() string {
-	return self.major + "/" + self.minor
+// defaultTarget is the query for the platforms to target: -target, or the configured target
+func defaultTarget() string {
+	if *flag_target != "" {
+		return *flag_target
+	}
+	return config.Target
 }
 
-func (self _platform) native() bool {
-	return self.major == goHostMajor && self.minor == goHostMinor
+func bashrc() {
+	shellInit(os.Stdout, "bash")
+	os.Exit(0)
 }
 
-// ${GOROOT}/pkg/${GOOS}_${GOARCH}/.gxc
-func (self _platform) builtFile() string {
-	return filepath.Join(goRoot, "pkg", self.major+"_"+self.minor, ".gxc")
+// interruptContext is canceled on SIGINT, SIGTERM, SIGHUP, or SIGQUIT (stopping every platform, and killing whatever is running)
+// A second signal is left to do what it normally does
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	// (Whatever is running is in a process group of its own, and would not get SIGHUP or SIGQUIT from the terminal)
+	signal.Notify(interrupt, interruptSignal...)
+	go func() {
+		select {
+		case received := <-interrupt:
+			logger.Warnf("%s, stopping", received)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(interrupt)
+	}()
+	return ctx, cancel
 }
 
-func (self _platform) match(query string) bool {
-	switch query {
-	case "", "*", "all":
-		return true
+// failureSummary lists the failed platforms, with those that timed out (etc.) listed separately:
+// linux/arm windows/386; timed out: darwin/arm64; canceled: linux/386; skipped: plan9/386 (not setup)
+func failureSummary(failure cross.Results) string {
+	group := map[string][]string{}
+	for _, failure := range failure {
+		status := failure.Status()
+		group[status] = append(group[status], skipSummary(failure))
 	}
-	if match := matchPlatformQuery.FindStringSubmatch(query); match != nil {
-		targetMajor, targetMinor := match[1], match[2]
-		switch targetMajor {
-		case "", "*":
-		default:
-			if targetMajor != self.major {
-				return false
-			}
-		}
-		switch targetMinor {
-		case "", "*":
-		default:
-			if targetMinor != self.minor {
-				return false
-			}
+	summary := []string{}
+	if platform := group["failed"]; len(platform) > 0 {
+		summary = append(summary, strings.Join(platform, " "))
+	}
+	for _, status := range []string{"timed out", "interrupted", "canceled", "skipped"} {
+		if platform := group[status]; len(platform) > 0 {
+			summary = append(summary, status+": "+strings.Join(platform, " "))
 		}
-		return true
 	}
-	return false
-}
-
-func (self _platform) isReady() bool {
-	_, err := os.Stat(self.builtFile())
-	return err == nil
+	return strings.Join(summary, "; ")
 }
 
-func (self _platform) cgoFlag() string {
-	if self.native() {
-		return "CGO_ENABLED=1"
+// attemptSummary is the platform of result, along with the number of attempts (if more than one): linux/arm (3 attempts)
+func attemptSummary(result cross.Result) string {
+	if result.Attempts > 1 {
+		return fmt.Sprintf("%s (%d attempts)", result.Platform, result.Attempts)
 	}
-	return "CGO_ENABLED=0"
+	return result.Platform.String()
 }
 
-func (self _platform) buildCompiler(stdout io.Writer, stderr io.Writer) error {
-
-	cmd := exec.Command(filepath.Join(goRoot, "src", hostPlatform.buildMake), "--no-clean")
-	cmd.Dir = filepath.Dir(cmd.Path)
-	cmd.Env = environment(
-		"GOOS="+self.major,
-		"GOARCH="+self.minor,
-		self.cgoFlag(), // CGO_ENABLED=
-	)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = stdout
-	cmd.Stderr = stderr
-	err := cmd.Run()
-	if err != nil {
-		return err
-	}
-	{
-		path := self.builtFile()
-		file, err := os.Create(path)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		return err
+// skipSummary is attemptSummary, along with why the platform was skipped (if it was): plan9/386 (not setup)
+func skipSummary(result cross.Result) string {
+	if result.Skip != "" {
+		return fmt.Sprintf("%s (%s)", result.Platform, result.Skip)
 	}
+	return attemptSummary(result)
 }
 
-// darwin/386
-// darwin/amd64
-// freebsd/386
-// freebsd/amd64
-// linux/386
-// linux/amd64
-// linux/arm
-// windows/386
-// windows/amd64
-
-var registry []_platform
-
-func firstTimeSetup(target []_platform) {
-	for _, platform := range target {
-		// If at least one platform is ready, then return
-		// Assume the user has already tried to setup before
-		// (We do not want to keep trying to run a slow, broken make.bash)
-		if platform.isReady() {
-			return
+// retriedSummary lists every platform that was retried (whether it then succeeded or not)
+func retriedSummary(results cross.Results) string {
+	retried := []string{}
+	for _, result := range results {
+		if result.Attempts > 1 {
+			retried = append(retried, attemptSummary(result))
 		}
 	}
-	doSetup(target, nil)
+	return strings.Join(retried, " ")
 }
 
-func doSetup(target []_platform, arguments []string) (failure []_failure) {
-	setupFlag.Parse(arguments)
-	arguments = setupFlag.Args()
-	if len(arguments) > 0 {
-		// e.g. $ gxc setup windows linux-amd64 freebsd-386
-		target = nil
-		for _, query := range arguments {
-			target = append(target, platformQuery(query)...)
-		}
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	logger = cross.NewLogger(os.Stderr, cross.LevelInfo)
+	logger.JSON = *flag_logJSON
+	if *flag_verbose {
+		logger.Level = cross.LevelDebug
 	}
-
-	// A bulk setup is doing more than one
-	bulk := len(target) > 1
-
-	for _, platform := range target {
-		if bulk && platform.native() {
-			continue
-		}
-		if setupFlag_force {
-			os.Remove(platform.builtFile())
-		}
-		if platform.isReady() {
-			fmt.Fprintf(os.Stderr, "+ %s\n", platform)
-			continue
-		}
-
-		var stdout, stderr io.Writer
-		var log *os.File
-		emit := ""
-		if setupFlag_verbose {
-			emit = "-"
-			stdout = os.Stdout
-			stderr = os.Stderr
-		} else if setupFlag_quiet {
-		} else {
-			log, _ = ioutil.TempFile("", "make."+platform.major+"-"+platform.minor+".log.")
-			if log != nil {
-				defer log.Close()
-				stdout = log
-				stderr = log
-				emit = log.Name()
-			}
-		}
-		fmt.Fprintf(os.Stderr, "- %s\n", platform)
-		fmt.Fprintf(os.Stderr, "# Building platform: %s (%s)\n", platform, emit)
-		err := platform.buildCompiler(stdout, stderr)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "! %s: %s\n", platform, err)
-			failure = append(failure, _failure{
-				platform: platform,
+	if *flag_quiet {
+		logger.Level = cross.LevelError
+	}
+	err := func() error {
+		{
+			required := false
+			flag.Visit(func(flag *flag.Flag) {
+				if flag.Name == "config" {
+					required = true
+				}
 			})
-		} else {
-			if log != nil {
-				if false {
-					// Should we do this?
-					os.Remove(log.Name())
+			err := loadConfig(*flag_config, required)
+			if err != nil {
+				return environmentError(err)
+			}
+			for _, hook := range []struct {
+				flag  string
+				value *string
+			}{
+				{*flag_beforeAll, &config.Hook.BeforeAll},
+				{*flag_beforePlatform, &config.Hook.BeforePlatform},
+				{*flag_afterPlatform, &config.Hook.AfterPlatform},
+				{*flag_afterAll, &config.Hook.AfterAll},
+			} {
+				if hook.flag != "" {
+					*hook.value = hook.flag
 				}
 			}
-			fmt.Fprintf(os.Stderr, "+ %s\n", platform)
 		}
-	}
-	return failure
-}
-
-func findBuiltName(arguments []string) (name string) {
-	name = "build"
-	cmd := exec.Command("go", append([]string{"build", "-n"}, arguments...)...)
-	output, err := cmd.Output()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "gxc: unable to guess built name: %v\n", err)
-		return
-	}
-	if match := matchBuiltPackage.FindAllSubmatch(output, -1); match != nil {
-		pkg := string(match[len(match)-1][1])
-		name = filepath.Base(pkg)
-	} else {
-		fmt.Fprintf(os.Stderr, "gxc: unable to guess built name\n")
-	}
-	return
-}
-
-func doBuild(target []_platform, arguments []string) (failure []_failure) {
-	firstTimeSetup(target)
-	name := findBuiltName(arguments)
 
-	stash := *flag_stash
-	if stash != "" {
-		stash = filepath.Clean(stash)
-		os.MkdirAll(stash, 0777) // Ignore error, "go build" will squawk below
-	}
-
-	for _, platform := range target {
-		if !platform.isReady() {
-			continue
-		}
-		output := strings.Join([]string{name, platform.major, platform.minor}, "-")
-		if stash != "" {
-			output = filepath.Join(stash, output)
-		}
-		if *flag_exe && platform.major == "windows" {
-			output += ".exe"
-		}
-		fmt.Fprintf(os.Stderr, "# Build: %s\n", output)
-		cmd := exec.Command("go", append([]string{"build", "-o", output}, arguments...)...)
-		cmd.Env = environment(
-			"GOOS="+platform.major,
-			"GOARCH="+platform.minor,
-			platform.cgoFlag(), // CGO_ENABLED=
-		)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err := cmd.Run()
-		if err != nil {
-			failure = append(failure, _failure{
-				platform: platform,
-			})
-			if !flag_quiet {
-				fmt.Fprintf(os.Stderr, "! %s: %s\n", platform, err)
+		{
+			var err error
+			toolchain, err = cross.NewToolchain()
+			if err != nil {
+				return environmentError(err)
+			}
+			toolchain.Env, err = buildEnv()
+			if err != nil {
+				return err
 			}
 		}
-	}
-	return failure
-}
 
-func doGo(target []_platform, arguments []string) (failure []_failure) {
-	firstTimeSetup(target)
-	for _, platform := range target {
-		if !platform.isReady() {
-			continue
-		}
-		cmd := exec.Command("go", arguments...)
-		cmd.Env = environment(
-			"GOOS="+platform.major,
-			"GOARCH="+platform.minor,
-			platform.cgoFlag(), // CGO_ENABLED=
-		)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err := cmd.Run()
-		if err != nil {
-			failure = append(failure, _failure{
-				platform: platform,
-			})
-			if !flag_quiet {
-				fmt.Fprintf(os.Stderr, "! %s: %s\n", platform, err)
+		toolchain.NoNativeCgo = !*flag_nativeCgo || (config.NativeCgo != nil && !*config.NativeCgo)
+
+		if *flag_isolate || config.Isolate {
+			err := toolchain.Isolate(logger, *flag_dryRun)
+			if err != nil {
+				return environmentError(err)
 			}
 		}
-	}
-	return failure
-}
 
-func platformQuery(query string) []_platform {
-	switch query {
-	case "", "all":
-		return registry
-	}
-	found := []_platform{}
-	for _, query := range strings.Fields(query) {
-		for _, platform := range registry {
-			if platform.match(query) {
-				found = append(found, platform)
+		{
+			var err error
+			registry, err = cross.NewRegistry(toolchain)
+			if err != nil {
+				logger.Warnf("unable to populate platform registry: %s", err)
 			}
+			registry.Group = config.Group
 		}
-	}
-	return found
-}
 
-func platformMatch(query []string) ([]_platform, []string) {
-	index := 0
-	match := []_platform{}
-	for _, query := range query {
-		found := platformQuery(query)
-		if len(found) == 0 {
-			break
+		builder = cross.NewBuilder(toolchain,
+			cross.WithStash(*flag_stash),
+			cross.WithHook(config.Hook),
+			cross.WithLog(logger),
+		)
+		builder.Exe = *flag_exe
+		builder.Strip = *flag_strip
+		builder.Compress = *flag_compress
+		if *flag_failFast && *flag_keepGoing {
+			return usageError("-fail-fast and -keep-going are mutually exclusive")
+		}
+		builder.FailFast = *flag_failFast
+		builder.Timeout = *flag_timeout
+		if *flag_retries < 0 {
+			return usageError("invalid -retries: %d", *flag_retries)
+		}
+		builder.Retries = *flag_retries
+		if *flag_dryRun || *flag_print {
+			// Like go, to stderr
+			builder.Print = os.Stderr
+		}
+		builder.DryRun = *flag_dryRun
+		builder.Native = config.Native
+		if *flag_native != "" {
+			builder.Native = *flag_native
+		}
+		switch builder.Native {
+		case "", cross.NativeInclude, cross.NativeExclude, cross.NativeOnly:
+		default:
+			return usageError("invalid -native: %s (include, exclude, or only)", builder.Native)
 		}
-		match = append(match, found...)
-		index += 1
-	}
-	if index < len(query) {
-		query = query[index:]
-	} else {
-		query = []string(nil)
-	}
-	return match, query
-}
-
-func bashrc() {
-	fmt.Fprintf(os.Stdout, kilt.GraveTrim(`
-GXC_TARGET=();
-
-function go-crosscompile-build {
-_GXC_QUIET=1 gxc setup "$@"
-};
-
-function go-build-all {
-_GXC_QUIET=1 gxc -target="$GXC_TARGET" build "$@"
-};
-
-function go-all {
-_GXC_QUIET=1 gxc -target="$GXC_TARGET" go "$@"
-};
-
-    `))
-
-	for _, platform := range platformQuery(*flag_target) {
-		fmt.Fprintf(os.Stdout, kilt.GraveTrim(`
-GXC_TARGET+=("%s");
-function go-%s-%s {
-_GXC_QUIET=1 gxc -target="%s" go "$@"
-};
-
-        `), platform, platform.major, platform.minor, platform)
-	}
-	os.Exit(0)
-}
 
-func main() {
-	flag.Usage = usage
-	flag.Parse()
-	if os.ExpandEnv("$_GXC_QUIET") == "1" {
-		flag_quiet = true
-	}
-	if runtime.GOOS == "windows" {
-		hostPlatform = platformWindows
-	}
-	err := func() error {
 		{
-			// We want to have a pure go environment, to fix any fiddling
-			// go env:
-			output, err := exec.Command("go", "env").Output()
+			var err error
+			builder.Module, err = buildModule()
 			if err != nil {
 				return err
 			}
-			if match := matchKeyValue.FindAllSubmatch(output, -1); match != nil {
-				for _, match := range match[1:] {
-					key, value := string(match[1]), string(match[2])
-					if match := matchQuote.FindStringSubmatch(value); match != nil {
-						value = match[1]
-					}
-					switch key {
-					case "GOROOT":
-						goRoot = value
-					case "GOHOSTOS":
-						goHostMajor = value
-					case "GOHOSTARCH":
-						goHostMinor = value
-					}
-					os.Setenv(key, value)
-				}
-			} else {
-				return fmt.Errorf(`missing Go environment (go env)`)
-			}
 		}
 
-		{
-			file, err := os.Open(filepath.Join(goRoot, "src", "pkg", "runtime"))
-			if err == nil {
-				files, err := file.Readdirnames(-1)
-				if err != nil {
-					fmt.Fprintln(os.Stderr, "gxc: problem populating platform registry", err)
-				}
-				for _, name := range files {
-					if strings.HasPrefix(name, "defs_") && strings.HasSuffix(name, ".h") {
-						name = name[5 : len(name)-2] // defs_*.h
-						index := strings.Index(name, "_")
-						major := name[0:index]
-						minor := name[index+1:]
-						registry = append(registry, _platform{
-							major: major,
-							minor: minor,
-						})
-					}
-				}
-
-			} else {
-				fmt.Fprintln(os.Stderr, "gxc: unable to populate platform registry", err)
-			}
+		ctx, cancel := interruptContext()
+		defer cancel()
+		if *flag_timeoutAll > 0 {
+			ctx, cancel = context.WithTimeout(ctx, *flag_timeoutAll)
+			defer cancel()
 		}
 
 		if *flag_bashrc {
@@ -688,7 +802,7 @@ func main() {
 			command = match[1]
 			query = match[2]
 		} else {
-			query = *flag_target
+			query = defaultTarget()
 		}
 
 		if command == "" {
@@ -696,51 +810,157 @@ func main() {
 			os.Exit(2)
 		} else {
 			arguments := flag.Args()[1:]
-			failure := []_failure{}
-			target := []_platform{}
+			start := time.Now()
+			results := cross.Results{}
+			var runErr error // Of the run as a whole (a hook, go mod download), rather than any platform
+			target := []cross.Platform{}
 			switch command {
 			case "build":
 				found := false
 				if query == "" {
-					target, arguments = platformMatch(arguments)
+					target, arguments = registry.Match(arguments)
 					found = len(target) > 0
 				}
 				if !found {
-					target = platformQuery(query)
+					target = registry.Query(query)
 				}
-				failure = doBuild(target, arguments)
+				results, runErr = builder.Build(ctx, target, arguments)
 			case "setup":
-				target = platformQuery(query)
-				failure = doSetup(target, arguments)
+				target = registry.Query(query)
+				results = doSetup(ctx, target, arguments)
 			case "go":
-				target = platformQuery(query)
-				failure = doGo(target, arguments)
+				target = registry.Query(query)
+				results, runErr = builder.Go(ctx, target, arguments)
+			case "size":
+				target = registry.Query(query)
+				var err error
+				results, err = doSize(target, arguments)
+				if err != nil {
+					return err
+				}
+			case "inspect":
+				target = registry.Query(query)
+				var err error
+				results, err = doInspect(target, arguments)
+				if err != nil {
+					return err
+				}
+			case "logs":
+				target = registry.Query(query)
+				err := doLogs(target, arguments)
+				if err != nil {
+					return err
+				}
+			case "clean":
+				target = registry.Query(query)
+				err := doClean(target, arguments)
+				if err != nil {
+					return err
+				}
+			case "env":
+				target = registry.Query(query)
+				err := doEnv(target, arguments)
+				if err != nil {
+					return err
+				}
 			case "list":
-				for _, platform := range registry {
-					ready := "-"
-					if platform.isReady() {
-						ready = "+"
-					}
-					fmt.Fprintf(os.Stdout, "%s %s\n", ready, platform)
+				target = registry.Query(query)
+				err := doList(target, arguments)
+				if err != nil {
+					return err
+				}
+			case "export":
+				if len(arguments) == 0 {
+					return usageError("missing format: export <format> (%s)", strings.Join(exportList, ", "))
+				}
+				format := arguments[0]
+				arguments = arguments[1:]
+				found := false
+				if query == "" {
+					target, arguments = registry.Match(arguments)
+					found = len(target) > 0
+				}
+				if !found {
+					target = registry.Query(query)
+				}
+				err := doExport(target, format, arguments)
+				if err != nil {
+					return err
 				}
 			case "bashrc":
 				bashrc()
+			case "shell-init", "completion":
+				if command == "shell-init" {
+					shellFlag.Parse(arguments)
+					arguments = shellFlag.Args()
+				}
+				if len(arguments) != 1 {
+					return usageError("missing shell: %s <shell> (%s)", command, strings.Join(shellList, ", "))
+				}
+				var err error
+				if command == "shell-init" {
+					err = shellInit(os.Stdout, arguments[0])
+				} else {
+					err = shellCompletion(os.Stdout, arguments[0])
+				}
+				if err != nil {
+					return err
+				}
+			case "__complete":
+				doComplete(arguments)
 			default:
-				return fmt.Errorf("invalid command: %s", command)
+				return usageError("invalid command: %s", command)
+			}
+			switch command {
+			case "build", "go", "setup":
+				wall := time.Since(start)
+				if len(results) == 0 {
+					break
+				}
+				if logger.Enabled(cross.LevelInfo) && !logger.JSON {
+					printSummary(os.Stderr, results, wall)
+				}
+				if *flag_summaryMD != "" {
+					err := writeSummaryMarkdown(*flag_summaryMD, command, results, wall)
+					if err != nil {
+						logger.Warnf("unable to write summary: %s", err)
+					}
+				}
+			}
+			if retried := retriedSummary(results); retried != "" {
+				logger.Infof("%s retried: %s", command, retried)
+			}
+			if runErr != nil {
+				return runErr
+			}
+			skipped := results.Skipped()
+			failure := results.Failed()
+			if *flag_requireAll || len(skipped) == len(results) {
+				// Every skip is a failure (as is skipping every platform, since nothing was done at all)
+				failure = append(failure, skipped...)
+			} else if len(skipped) != 0 {
+				list := []string{}
+				for _, result := range skipped {
+					list = append(list, skipSummary(result))
+				}
+				logger.Warnf("%s skipped (%d): %s", command, len(skipped), strings.Join(list, " "))
 			}
 			if len(failure) != 0 {
-				platform := []string{}
-				for _, failure := range failure {
-					platform = append(platform, failure.platform.String())
+				err := fmt.Errorf("%s failure (%d): %s", command, len(failure), failureSummary(failure))
+				if ok := len(results) - len(results.Failed()) - len(skipped); ok > 0 {
+					return _exitError{exitPartial, err}
 				}
-				return fmt.Errorf("%s failure (%d): %s", command, len(failure), strings.Join(platform, " "))
+				return _exitError{exitTotal, err}
 			}
 		}
 
 		return nil
 	}()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "gxc:", err)
-		os.Exit(1)
+		logger.Errorf("%s", err)
+		if err, is := err.(_exitError); is {
+			os.Exit(err.status)
+		}
+		os.Exit(exitFailure)
 	}
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+
+	"github.com/robertkrimen/gxc/cross"
+)
+
+//	"module": {
+//	    "dir": "cmd/xyzzy",
+//	    "modfile": "go.release.mod",
+//	    "mod": "readonly",
+//	    "workfile": "off",
+//	    "download": false
+//	}
+type _moduleConfig struct {
+	Dir      string `json:"dir"`      // Like -C
+	ModFile  string `json:"modfile"`  // Like -modfile
+	Mod      string `json:"mod"`      // Like -mod
+	WorkFile string `json:"workfile"` // Like -workfile
+	Download *bool  `json:"download"` // Like -download
+}
+
+// buildModule is where (and how) go finds the module being built, from the configuration and the command line (which comes last)
+func buildModule() (cross.Module, error) {
+	module := cross.Module{
+		Dir:       config.Module.Dir,
+		ModFile:   config.Module.ModFile,
+		Mod:       config.Module.Mod,
+		Workspace: config.Module.WorkFile,
+		Download:  *flag_download && (config.Module.Download == nil || *config.Module.Download),
+	}
+	for _, value := range []struct {
+		flag  string
+		value *string
+	}{
+		{*flag_dir, &module.Dir},
+		{*flag_modFile, &module.ModFile},
+		{*flag_mod, &module.Mod},
+		{*flag_workFile, &module.Workspace},
+	} {
+		if value.flag != "" {
+			*value.value = value.flag
+		}
+	}
+	switch module.Mod {
+	case "", "readonly", "vendor", "mod":
+	default:
+		return module, usageError("invalid -mod: %s (readonly, vendor, or mod)", module.Mod)
+	}
+	if module.Dir != "" {
+		if info, err := os.Stat(module.Dir); err != nil {
+			return module, usageError("invalid -C: %s", err)
+		} else if !info.IsDir() {
+			return module, usageError("invalid -C: %s is not a directory", module.Dir)
+		}
+	}
+	return module, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/robertkrimen/gxc/cross"
+)
+
+func doLogs(target []cross.Platform, arguments []string) error {
+	if len(arguments) > 0 {
+		// e.g. $ gxc logs linux-arm
+		target = nil
+		for _, query := range arguments {
+			target = append(target, registry.Query(query)...)
+		}
+	}
+	for _, platform := range target {
+		path := cross.LatestLog(platform)
+		if path == "" {
+			if len(target) == 1 {
+				return fmt.Errorf("%s: no setup log", platform)
+			}
+			continue
+		}
+		if len(target) > 1 {
+			fmt.Fprintf(os.Stdout, "# %s: %s\n", platform, path)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(os.Stdout, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
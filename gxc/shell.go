@@ -0,0 +1,312 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/robertkrimen/gxc/cross"
+)
+
+var shellList = []string{"bash", "zsh", "fish", "powershell"}
+
+var (
+	shellFlag          = flag.NewFlagSet("shell-init", flag.ExitOnError)
+	shellFlag_groups   = false
+	shellFlag_commands = false
+	_                  = func() byte {
+		shellFlag.BoolVar(&shellFlag_groups, "groups", shellFlag_groups, "Also emit functions for each configured group: go-<group>, go-build-<group>")
+		shellFlag.BoolVar(&shellFlag_commands, "commands", shellFlag_commands, "Also emit go-setup-all, go-check-all (go vet), and go-test-all")
+		return 0
+	}()
+)
+
+// The syntax of a shell, enough to emit gxc functions
+type _shell struct {
+	quote    func(string) string
+	header   string // Declares GXC_TARGET (and anything else needed)
+	append   string // Appends a platform (%s) to GXC_TARGET
+	function string // Defines a function (%[1]s) that runs gxc with arguments (%[2]s)
+	target   string // The target for all of GXC_TARGET
+}
+
+var matchShellName = regexp.MustCompile(`^[0-9A-Za-z_\-]+$`)
+
+// quotePosix single quotes value for bash (and zsh), escaping each ' outside of the quotes
+func quotePosix(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// quoteFish single quotes value for fish, escaping each \ and ' with \
+func quoteFish(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	return "'" + strings.Replace(value, "'", `\'`, -1) + "'"
+}
+
+// quotePowerShell single quotes value for PowerShell, doubling each '
+func quotePowerShell(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+var shellSyntax = map[string]_shell{
+	"bash": {
+		quote:  quotePosix,
+		header: "GXC_TARGET=();\n",
+		append: "GXC_TARGET+=(%s);\n",
+		function: kilt.GraveTrim(`
+function %[1]s {
+gxc -q %[2]s "$@"
+};
+        `) + "\n",
+		target: `-target="${GXC_TARGET[*]}"`,
+	},
+	"fish": {
+		quote:  quoteFish,
+		header: "set -g GXC_TARGET\n",
+		append: "set -ga GXC_TARGET %s\n",
+		function: kilt.GraveTrim(`
+function %[1]s
+    gxc -q %[2]s $argv
+end
+        `) + "\n",
+		target: `-target="$GXC_TARGET"`,
+	},
+	"powershell": {
+		quote:  quotePowerShell,
+		header: "$global:GXC_TARGET = @()\n",
+		append: "$global:GXC_TARGET += %s\n",
+		function: kilt.GraveTrim(`
+function global:%[1]s {
+    & gxc -q %[2]s @args
+}
+        `) + "\n",
+		target: `"-target=$($global:GXC_TARGET -join ' ')"`,
+	},
+}
+
+// shellInit writes the functions (go-build-all, go-linux-386, ...) and completion for shell
+func shellInit(writer io.Writer, shell string) error {
+	switch shell {
+	case "zsh":
+		// zsh is close enough to bash to share functions
+		shellFunctions(writer, shellSyntax["bash"])
+	case "pwsh":
+		shellFunctions(writer, shellSyntax["powershell"])
+	default:
+		syntax, exists := shellSyntax[shell]
+		if !exists {
+			return usageError("invalid shell: %s (%s)", shell, strings.Join(shellList, ", "))
+		}
+		shellFunctions(writer, syntax)
+	}
+	return shellCompletion(writer, shell)
+}
+
+func shellFunctions(writer io.Writer, shell _shell) {
+	function := func(name string, target string, argument ...string) {
+		if !matchShellName.MatchString(name) {
+			logger.Warnf("skipping function with an invalid name: %s", name)
+			return
+		}
+		for index, value := range argument {
+			argument[index] = shell.quote(value)
+		}
+		if target != "" {
+			argument = append([]string{target}, argument...)
+		}
+		fmt.Fprintf(writer, shell.function, name, strings.Join(argument, " "))
+	}
+	// A target for a list of platforms: -target='linux/386 linux/amd64'
+	literal := func(platform []cross.Platform) string {
+		query := []string{}
+		for _, platform := range platform {
+			query = append(query, platform.String())
+		}
+		return shell.quote("-target=" + strings.Join(query, " "))
+	}
+
+	fmt.Fprint(writer, shell.header)
+	for _, platform := range registry.Query(defaultTarget()) {
+		fmt.Fprintf(writer, shell.append, shell.quote(platform.String()))
+	}
+	fmt.Fprintln(writer)
+
+	function("go-crosscompile-build", "", "setup")
+	function("go-build-all", shell.target, "build")
+	function("go-all", shell.target, "go")
+	if shellFlag_commands {
+		function("go-setup-all", shell.target, "setup")
+		function("go-check-all", shell.target, "go", "vet")
+		function("go-test-all", shell.target, "go", "test")
+	}
+	for _, platform := range registry.Query(defaultTarget()) {
+		function("go-"+platform.OS+"-"+platform.Arch, literal([]cross.Platform{platform}), "go")
+	}
+	if shellFlag_groups {
+		name := []string{}
+		for group := range config.Group {
+			name = append(name, group)
+		}
+		sort.Strings(name)
+		for _, group := range name {
+			target := literal(registry.Query(group))
+			function("go-"+group, target, "go")
+			function("go-build-"+group, target, "build")
+			if shellFlag_commands {
+				function("go-setup-"+group, target, "setup")
+			}
+		}
+	}
+}
+
+// shellCompletion writes the completion for shell, which defers to "gxc __complete"
+func shellCompletion(writer io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		fmt.Fprint(writer, kilt.GraveTrim(`
+_gxc() {
+    local IFS=$'\n'
+    COMPREPLY=($(gxc __complete -- "${COMP_WORDS[@]:1:$COMP_CWORD}" 2>/dev/null))
+};
+complete -o default -F _gxc gxc;
+
+        `))
+	case "zsh":
+		fmt.Fprint(writer, kilt.GraveTrim(`
+_gxc() {
+    local -a candidate
+    candidate=("${(@f)$(gxc __complete -- "${(@)words[2,$CURRENT]}" 2>/dev/null)}")
+    if [[ -n "${candidate[1]}" ]]; then
+        compadd -a candidate
+    else
+        _files
+    fi
+};
+compdef _gxc gxc;
+
+        `))
+	case "fish":
+		fmt.Fprint(writer, kilt.GraveTrim(`
+complete -c gxc -a '(gxc __complete -- (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'
+
+        `))
+	case "powershell", "pwsh":
+		fmt.Fprint(writer, kilt.GraveTrim(`
+Register-ArgumentCompleter -Native -CommandName gxc -ScriptBlock {
+    param($word, $ast, $cursor)
+    $words = @($ast.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+    if ($word -eq '') { $words += '""' }
+    & gxc __complete -- @words 2>$null | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+
+        `))
+	default:
+		return usageError("invalid shell: %s (%s)", shell, strings.Join(shellList, ", "))
+	}
+	return nil
+}
+
+// The commands that can be combined with a platform: build-linux, setup-windows-386, etc.
+var compoundCommand = []string{"build", "clean", "export", "go", "logs", "setup", "size"}
+
+var commandFlag = map[string]*flag.FlagSet{
+	"clean":      cleanFlag,
+	"list":       listFlag,
+	"setup":      setupFlag,
+	"shell-init": shellFlag,
+	"size":       sizeFlag,
+}
+
+// flagNames is every (visible) flag of a flag set: -force, -quiet, ...
+func flagNames(set *flag.FlagSet) []string {
+	names := []string{}
+	set.VisitAll(func(flag *flag.Flag) {
+		if flag.Usage == string(rune(0)) {
+			return // A hidden alias
+		}
+		names = append(names, "-"+flag.Name)
+	})
+	return names
+}
+
+// complete finds the candidates for the last of words (the words of the command line, after gxc)
+func complete(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+	words = words[:len(words)-1]
+
+	command := ""
+	for _, word := range words {
+		if !strings.HasPrefix(word, "-") {
+			command = word
+			break
+		}
+	}
+	if match := matchCompoundCommand.FindStringSubmatch(command); match != nil {
+		command = match[1]
+	}
+
+	candidate := []string{}
+	switch {
+	case command == "" && strings.HasPrefix(current, "-"):
+		candidate = flagNames(flag.CommandLine)
+	case command == "":
+		candidate = append(candidate, "build", "clean", "completion", "env", "export", "go", "inspect", "list", "logs", "setup", "shell-init", "size")
+		seen := map[string]bool{}
+		for _, platform := range registry.Platforms {
+			for _, command := range compoundCommand {
+				if !seen[platform.OS] {
+					candidate = append(candidate, command+"-"+platform.OS)
+				}
+				candidate = append(candidate, command+"-"+platform.OS+"-"+platform.Arch)
+			}
+			seen[platform.OS] = true
+		}
+	case strings.HasPrefix(current, "-"):
+		if set, exists := commandFlag[command]; exists {
+			candidate = flagNames(set)
+		}
+	case command == "shell-init" || command == "completion":
+		candidate = shellList
+	case command == "inspect":
+		// A directory, which the shell can do better
+	default:
+		for group := range config.Group {
+			candidate = append(candidate, group)
+		}
+		seen := map[string]bool{}
+		for _, platform := range registry.Platforms {
+			if !seen[platform.OS] {
+				candidate = append(candidate, platform.OS)
+				seen[platform.OS] = true
+			}
+			candidate = append(candidate, platform.String())
+		}
+	}
+
+	result := []string{}
+	for _, candidate := range candidate {
+		if strings.HasPrefix(candidate, current) {
+			result = append(result, candidate)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func doComplete(arguments []string) {
+	if len(arguments) > 0 && arguments[0] == "--" {
+		arguments = arguments[1:]
+	}
+	for _, candidate := range complete(arguments) {
+		fmt.Fprintln(os.Stdout, candidate)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		value      string
+		posix      string
+		fish       string
+		powerShell string
+	}{
+		{"linux/amd64", `'linux/amd64'`, `'linux/amd64'`, `'linux/amd64'`},
+		{"", `''`, `''`, `''`},
+		{"it's", `'it'\''s'`, `'it\'s'`, `'it''s'`},
+		{`C:\Go\bin`, `'C:\Go\bin'`, `'C:\\Go\\bin'`, `'C:\Go\bin'`},
+		{"$HOME `id` $(id)", "'$HOME `id` $(id)'", "'$HOME `id` $(id)'", "'$HOME `id` $(id)'"},
+	}
+	for _, test := range tests {
+		if have := quotePosix(test.value); have != test.posix {
+			t.Errorf("quotePosix(%q) = %s, want %s", test.value, have, test.posix)
+		}
+		if have := quoteFish(test.value); have != test.fish {
+			t.Errorf("quoteFish(%q) = %s, want %s", test.value, have, test.fish)
+		}
+		if have := quotePowerShell(test.value); have != test.powerShell {
+			t.Errorf("quotePowerShell(%q) = %s, want %s", test.value, have, test.powerShell)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/robertkrimen/gxc/cross"
+)
+
+var (
+	sizeFlag          = flag.NewFlagSet("size", flag.ExitOnError)
+	sizeFlag_baseline = ""
+	sizeFlag_budget   = 0.0
+	sizeFlag_save     = false
+	_                 = func() byte {
+		sizeFlag.StringVar(&sizeFlag_baseline, "baseline", sizeFlag_baseline, "A baseline file (JSON) to compare against")
+		sizeFlag.Float64Var(&sizeFlag_budget, "budget", sizeFlag_budget, "Fail when a platform grows by more than this percentage")
+		sizeFlag.BoolVar(&sizeFlag_save, "save", sizeFlag_save, "Write the current sizes to the baseline file")
+		return 0
+	}()
+)
+
+// A baseline maps a platform (linux/amd64) to the size of its built file
+type _baseline map[string]int64
+
+func readBaseline(path string) (_baseline, error) {
+	baseline := _baseline{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return baseline, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(data, &baseline)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return baseline, nil
+}
+
+func writeBaseline(path string, baseline _baseline) error {
+	data, err := json.MarshalIndent(baseline, "", "    ")
+	if err != nil {
+		return err
+	}
+	return kilt.WriteAtomicFile(path, bytes.NewReader(append(data, '\n')), 0666)
+}
+
+func doSize(target []cross.Platform, arguments []string) (results cross.Results, err error) {
+	sizeFlag.Parse(arguments)
+	arguments = sizeFlag.Args()
+
+	path := config.Size.Baseline
+	if sizeFlag_baseline != "" {
+		path = sizeFlag_baseline
+	}
+	budget := config.Size.Budget
+	if sizeFlag_budget != 0 {
+		budget = sizeFlag_budget
+	}
+
+	baseline := _baseline{}
+	if path != "" {
+		baseline, err = readBaseline(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	name := findBuiltName(arguments)
+	current := _baseline{}
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, platform := range target {
+		output := builder.Output(name, platform)
+		info, err := os.Stat(output)
+		if err != nil {
+			fmt.Fprintf(table, "%s\t%s\t-\t\n", platform, output)
+			continue
+		}
+		size := info.Size()
+		current[platform.String()] = size
+		result := cross.Result{
+			Platform: platform,
+			Output:   output,
+		}
+
+		change := ""
+		if before, exists := baseline[platform.String()]; exists && before > 0 {
+			percent := float64(size-before) * 100 / float64(before)
+			change = fmt.Sprintf("%+.1f%%", percent)
+			if budget > 0 && percent > budget {
+				change += " !"
+				result.Err = fmt.Errorf("%s over budget (%+.1f%% > %g%%)", output, percent, budget)
+			}
+		}
+		results = append(results, result)
+		fmt.Fprintf(table, "%s\t%s\t%d\t%s\n", platform, output, size, change)
+	}
+	table.Flush()
+
+	if sizeFlag_save {
+		if path == "" {
+			return results, usageError("missing baseline file (-baseline)")
+		}
+		for key, value := range current {
+			baseline[key] = value
+		}
+		if *flag_dryRun {
+			logger.Infof("Save: %s (dry run, not written)", path)
+			return results, nil
+		}
+		err = writeBaseline(path, baseline)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
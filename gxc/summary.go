@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/robertkrimen/gxc/cross"
+)
+
+// summaryStatus is the status of result in the summary: ok, failed, skipped (not setup), timed out (2 attempts), etc.
+func summaryStatus(result cross.Result) string {
+	status := result.Status()
+	if result.Skip != "" {
+		status += " (" + result.Skip + ")"
+	}
+	if result.Attempts > 1 {
+		status += fmt.Sprintf(" (%d attempts)", result.Attempts)
+	}
+	return status
+}
+
+// summaryRow is result in the summary: platform, status, duration, output, and size (before and after, with -compress)
+func summaryRow(result cross.Result) []string {
+	duration, output, size := "-", "-", "-"
+	if result.Duration > 0 {
+		duration = roundDuration(result.Duration).String()
+	}
+	if result.Output != "" && result.Status() == "ok" {
+		output = result.Output
+	}
+	if result.Compression != nil && result.Status() == "ok" {
+		size = result.Compression.Sizes() // With upx: 5678 => 1234 (21%)
+	} else if result.Size > 0 {
+		size = fmt.Sprint(result.Size)
+	}
+	return []string{result.Platform.String(), summaryStatus(result), duration, output, size}
+}
+
+// summaryTotal is every result, counted by status, along with the wall time: 4 platforms in 1m2.3s: 2 ok, 1 failed, 1 skipped
+func summaryTotal(results cross.Results, wall time.Duration) string {
+	count := map[string]int{}
+	status := []string{}
+	for _, result := range results {
+		if count[result.Status()] == 0 {
+			status = append(status, result.Status())
+		}
+		count[result.Status()] += 1
+	}
+	total := []string{}
+	for _, status := range status {
+		total = append(total, fmt.Sprintf("%d %s", count[status], status))
+	}
+	platforms := "platforms"
+	if len(results) == 1 {
+		platforms = "platform"
+	}
+	return fmt.Sprintf("%d %s in %s: %s", len(results), platforms, roundDuration(wall), strings.Join(total, ", "))
+}
+
+// roundDuration rounds duration to a millisecond (under a second) or a tenth of a second
+func roundDuration(duration time.Duration) time.Duration {
+	if duration < time.Second {
+		return duration.Round(time.Millisecond)
+	}
+	return duration.Round(100 * time.Millisecond)
+}
+
+// printSummary prints results as a table (for a terminal), followed by the total
+func printSummary(writer io.Writer, results cross.Results, wall time.Duration) {
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(table, "PLATFORM\tSTATUS\tDURATION\tOUTPUT\tSIZE\n")
+	for _, result := range results {
+		fmt.Fprintf(table, "%s\n", strings.Join(summaryRow(result), "\t"))
+	}
+	table.Flush()
+	fmt.Fprintf(writer, "Total: %s\n", summaryTotal(results, wall))
+}
+
+// writeSummaryMarkdown appends results, as a Markdown table (followed by the total), to path (or stdout, for -)
+// Appending allows for more than one run, and is what $GITHUB_STEP_SUMMARY expects
+func writeSummaryMarkdown(path string, command string, results cross.Results, wall time.Duration) error {
+	var markdown bytes.Buffer
+	fmt.Fprintf(&markdown, "### gxc %s\n\n", command)
+	fmt.Fprintf(&markdown, "| Platform | Status | Duration | Output | Size |\n")
+	fmt.Fprintf(&markdown, "| --- | --- | ---: | --- | ---: |\n")
+	for _, result := range results {
+		row := summaryRow(result)
+		for index, cell := range row {
+			row[index] = strings.Replace(cell, "|", `\|`, -1)
+		}
+		if row[3] != "-" {
+			row[3] = "`" + row[3] + "`"
+		}
+		fmt.Fprintf(&markdown, "| %s |\n", strings.Join(row, " | "))
+	}
+	fmt.Fprintf(&markdown, "\n**Total:** %s\n\n", summaryTotal(results, wall))
+
+	if path == "-" {
+		_, err := os.Stdout.Write(markdown.Bytes())
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(markdown.Bytes())
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}